@@ -1,3 +1,5 @@
 module example
 
 go 1.16
+
+require golang.org/x/crypto v0.1.0
@@ -0,0 +1,280 @@
+package httpd
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+//BasicAuth解析Authorization: Basic <base64(username:password)>
+func (r *Request) BasicAuth() (username, password string, ok bool) {
+	credentials, ok := authorization(r.Header.Get("Authorization"), "Basic")
+	if !ok {
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		return "", "", false
+	}
+	s := string(data)
+	index := strings.IndexByte(s, ':')
+	if index == -1 {
+		return "", "", false
+	}
+	return s[:index], s[index+1:], true
+}
+
+//BearerToken解析Authorization: Bearer <token>
+func (r *Request) BearerToken() (token string, ok bool) {
+	token, ok = authorization(r.Header.Get("Authorization"), "Bearer")
+	if !ok || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+//authorization的格式为"<scheme> <credentials>"，scheme不区分大小写
+func authorization(auth, scheme string) (string, bool) {
+	if len(auth) <= len(scheme) || !strings.EqualFold(auth[:len(scheme)], scheme) || auth[len(scheme)] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(scheme)+1:]), true
+}
+
+type Authenticator interface {
+	Authenticate(username, password string) bool
+}
+
+//Htpasswd保存htpasswd格式的用户名和密码哈希，每行一个"username:hash"，
+//'#'开头的行为注释。支持的哈希格式：
+//  {SHA256}base64(sha256(password))
+//  {SSHA256}base64(sha256(password+salt)+salt)
+//  {SHA}base64(sha1(password))，即htpasswd -s生成的格式
+//  $2y$、$2a$、$2b$开头的bcrypt，即htpasswd -B生成的格式，推荐使用
+//  $apr1$开头的Apache MD5，即htpasswd默认生成的格式
+//SHA和MD5都是快速哈希，泄露后容易被暴力破解，新生成的密码应当使用bcrypt
+type Htpasswd struct {
+	users map[string]string
+	//用户不存在时用来比较的哈希，取自文件中的第一个用户，
+	//使不存在的用户和存在的用户花费相同的校验时间
+	dummy string
+}
+
+func LoadHtpasswd(filename string) (*Htpasswd, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseHtpasswd(file)
+}
+
+func ParseHtpasswd(r io.Reader) (*Htpasswd, error) {
+	h := &Htpasswd{users: make(map[string]string)}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		index := strings.IndexByte(line, ':')
+		if index <= 0 {
+			return nil, errors.New("htpasswd: malformed line " + strconv.Itoa(lineNum))
+		}
+		user, hash := line[:index], line[index+1:]
+		if !supportedHash(hash) {
+			return nil, errors.New("htpasswd: unsupported hash for user " + user + " at line " + strconv.Itoa(lineNum))
+		}
+		h.users[user] = hash
+		if h.dummy == "" {
+			h.dummy = hash
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func supportedHash(hash string) bool {
+	if isBcrypt(hash) {
+		_, err := bcrypt.Cost([]byte(hash))
+		return err == nil
+	}
+	if strings.HasPrefix(hash, apr1Magic) {
+		salt, sum, ok := splitAPR1(hash)
+		return ok && salt != "" && len(sum) == 22
+	}
+	for _, prefix := range []string{"{SHA256}", "{SSHA256}", "{SHA}"} {
+		if strings.HasPrefix(hash, prefix) {
+			_, err := base64.StdEncoding.DecodeString(hash[len(prefix):])
+			return err == nil
+		}
+	}
+	return false
+}
+
+//dummyHash用于用户不存在时同样进行一次哈希比较，避免通过响应时间判断用户是否存在。
+//文件中没有任何用户时使用
+const dummyHash = "{SHA256}47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+func (h *Htpasswd) Authenticate(username, password string) bool {
+	hash, ok := h.users[username]
+	if !ok {
+		dummy := h.dummy
+		if dummy == "" {
+			dummy = dummyHash
+		}
+		checkHash(dummy, password)
+		return false
+	}
+	return checkHash(hash, password)
+}
+
+func checkHash(hash, password string) bool {
+	var want, got []byte
+	switch {
+	case isBcrypt(hash):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, apr1Magic):
+		salt, _, _ := splitAPR1(hash)
+		want, got = []byte(hash), []byte(apr1(password, salt))
+	case strings.HasPrefix(hash, "{SHA256}"):
+		want, _ = base64.StdEncoding.DecodeString(hash[len("{SHA256}"):])
+		sum := sha256.Sum256([]byte(password))
+		got = sum[:]
+	case strings.HasPrefix(hash, "{SSHA256}"):
+		want, _ = base64.StdEncoding.DecodeString(hash[len("{SSHA256}"):])
+		if len(want) <= sha256.Size {
+			return false
+		}
+		salt := want[sha256.Size:]
+		sum := sha256.Sum256(append([]byte(password), salt...))
+		got = append(sum[:], salt...)
+	case strings.HasPrefix(hash, "{SHA}"):
+		want, _ = base64.StdEncoding.DecodeString(hash[len("{SHA}"):])
+		sum := sha1.Sum([]byte(password))
+		got = sum[:]
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare(want, got) == 1
+}
+
+func isBcrypt(hash string) bool {
+	for _, prefix := range []string{"$2y$", "$2a$", "$2b$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+const apr1Magic = "$apr1$"
+
+//splitAPR1将"$apr1$salt$hash"拆分为salt和hash
+func splitAPR1(hash string) (salt, sum string, ok bool) {
+	rest := hash[len(apr1Magic):]
+	index := strings.IndexByte(rest, '$')
+	if index == -1 {
+		return "", "", false
+	}
+	return rest[:index], rest[index+1:], true
+}
+
+//apr1按照Apache的MD5-crypt算法计算password的哈希，返回"$apr1$salt$hash"的完整形式
+func apr1(password, salt string) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+	h := md5.New()
+	h.Write(pw)
+	h.Write([]byte(salt))
+	h.Write(pw)
+	alt := h.Sum(nil)
+
+	h = md5.New()
+	h.Write(pw)
+	h.Write([]byte(apr1Magic))
+	h.Write([]byte(salt))
+	for i := len(pw); i > 0; i -= 16 {
+		n := 16
+		if i < 16 {
+			n = i
+		}
+		h.Write(alt[:n])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	sum := h.Sum(nil)
+
+	//1000轮迭代只是为了拖慢计算
+	for i := 0; i < 1000; i++ {
+		h = md5.New()
+		if i&1 != 0 {
+			h.Write(pw)
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(pw)
+		}
+		if i&1 != 0 {
+			h.Write(sum)
+		} else {
+			h.Write(pw)
+		}
+		sum = h.Sum(nil)
+	}
+
+	//结果按特定的字节顺序，以crypt专用的base64字母表编码
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	buf := []byte(apr1Magic + salt + "$")
+	encode := func(v uint, n int) {
+		for ; n > 0; n-- {
+			buf = append(buf, itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, g := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint(sum[g[0]])<<16|uint(sum[g[1]])<<8|uint(sum[g[2]]), 4)
+	}
+	encode(uint(sum[11]), 2)
+	return string(buf)
+}
+
+//RequireBasicAuth包装next，只有通过auth校验的请求才会交给next处理，
+//否则回复401并带上WWW-Authenticate首部
+func RequireBasicAuth(realm string, auth Authenticator, next Handler) Handler {
+	challenge := `Basic realm=` + strconv.Quote(realm) + `, charset="UTF-8"`
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || !auth.Authenticate(username, password) {
+			w.Header().Set("WWW-Authenticate", challenge)
+			Error(w, r, StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
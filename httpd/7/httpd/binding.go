@@ -0,0 +1,398 @@
+package httpd
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//FieldError描述某一个字段绑定或者校验失败的原因
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+//BindError是DecodeJSON和Bind返回的错误，可以用Render直接以JSON回复给客户端
+type BindError struct {
+	Status  int          `json:"-"`
+	Message string       `json:"error"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+func (e *BindError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	msgs := make([]string, 0, len(e.Fields))
+	for _, fe := range e.Fields {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return e.Message + ": " + strings.Join(msgs, "; ")
+}
+
+//Render以JSON格式回复错误，状态码为e.Status，一般是400
+func (e *BindError) Render(w ResponseWriter) {
+	body, _ := json.Marshal(e)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(e.Status)
+	w.Write(body)
+}
+
+func bindError(status int, msg string) *BindError {
+	return &BindError{Status: status, Message: msg}
+}
+
+type JSONOptions struct {
+	//报文主体的最大长度，0代表使用默认的1MB
+	MaxBytes int64
+	//为true时，JSON中出现结构体没有的字段会返回错误
+	DisallowUnknownFields bool
+}
+
+const defaultMaxJSONBytes = 1 << 20
+
+//DecodeJSON使用默认选项解码JSON报文主体，见DecodeJSONWith
+func (r *Request) DecodeJSON(v interface{}) error {
+	return r.DecodeJSONWith(v, JSONOptions{})
+}
+
+//DecodeJSONWith将报文主体解码到v中，并按validate标签校验，出错时返回*BindError。
+//报文主体只能包含一个JSON值
+func (r *Request) DecodeJSONWith(v interface{}, opts JSONOptions) error {
+	//没有Content-Type时按JSON处理；有但无法解析时r.contentType为空，同样拒绝
+	_, hasCT := r.Header["Content-Type"]
+	if ct := r.contentType; hasCT && ct != "application/json" && !strings.HasSuffix(ct, "+json") {
+		return bindError(StatusUnsupportedMediaType, "content type must be application/json")
+	}
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxJSONBytes
+	}
+	dec := json.NewDecoder(MaxBytesReader(nil, r.Body, maxBytes))
+	if opts.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return jsonBindError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		if err == ErrBodyTooLarge {
+			return jsonBindError(err)
+		}
+		return bindError(StatusBadRequest, "body must contain a single JSON value")
+	}
+	return validateStruct(v, "json")
+}
+
+func jsonBindError(err error) *BindError {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case err == io.EOF:
+		return bindError(StatusBadRequest, "body must not be empty")
+	case err == io.ErrUnexpectedEOF:
+		return bindError(StatusBadRequest, "malformed JSON")
+	case err == ErrBodyTooLarge:
+		return bindError(StatusRequestEntityTooLarge, err.Error())
+	case errors.As(err, &syntaxErr):
+		return bindError(StatusBadRequest, "malformed JSON at offset "+strconv.FormatInt(syntaxErr.Offset, 10))
+	case errors.As(err, &typeErr):
+		e := bindError(StatusBadRequest, "invalid field")
+		e.Fields = []FieldError{{Field: typeErr.Field, Message: "must be " + typeErr.Type.String()}}
+		return e
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		e := bindError(StatusBadRequest, "unknown field")
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		e.Fields = []FieldError{{Field: field, Message: "is not allowed"}}
+		return e
+	default:
+		return bindError(StatusBadRequest, err.Error())
+	}
+}
+
+//Bind按照结构体标签从路由参数、表单和query string中取值填充v，v必须是结构体指针：
+//  type Query struct {
+//      ID   int      `path:"id"`
+//      Page int      `query:"page" validate:"min=1"`
+//      Tags []string `form:"tag" validate:"max=5"`
+//  }
+//一个字段可以同时有多个标签，按path、form、query的顺序取第一个存在的值。
+//支持string、bool、整数、浮点数、time.Time(RFC 3339或2006-01-02)以及它们的切片。
+//转换或校验失败时返回*BindError
+func (r *Request) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("httpd: Bind requires a pointer to struct")
+	}
+	var form Values
+	if _, ok := r.Body.(*eofReader); !ok {
+		switch r.contentType {
+		case "application/x-www-form-urlencoded", "multipart/form-data":
+			if !r.haveParsedForm {
+				r.parseFormErr = r.parseForm()
+			}
+			if r.parseFormErr == ErrBodyTooLarge || r.parseFormErr == ErrFormTooLarge {
+				return bindError(StatusRequestEntityTooLarge, r.parseFormErr.Error())
+			}
+			if r.parseFormErr != nil {
+				return bindError(StatusBadRequest, r.parseFormErr.Error())
+			}
+			form = r.postForm
+		}
+	}
+	e := bindError(StatusBadRequest, "invalid fields")
+	r.bindStruct(rv.Elem(), form, e)
+	if len(e.Fields) > 0 {
+		return e
+	}
+	return validateStruct(v, "")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (r *Request) bindStruct(rv reflect.Value, form Values, e *BindError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			r.bindStruct(fv, form, e)
+			continue
+		}
+		name, values := r.lookupBindValues(sf, form)
+		if values == nil {
+			continue
+		}
+		if err := setField(fv, values); err != nil {
+			e.Fields = append(e.Fields, FieldError{Field: name, Message: err.Error()})
+		}
+	}
+}
+
+func (r *Request) lookupBindValues(sf reflect.StructField, form Values) (string, []string) {
+	if name := sf.Tag.Get("path"); name != "" {
+		if v, ok := r.params[name]; ok {
+			return name, []string{v}
+		}
+	}
+	if name := sf.Tag.Get("form"); name != "" {
+		if vs, ok := form[name]; ok {
+			return name, vs
+		}
+	}
+	if name := sf.Tag.Get("query"); name != "" {
+		if vs, ok := r.queryString[name]; ok {
+			return name, vs
+		}
+	}
+	return "", nil
+}
+
+func setField(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setValue(fv, values[0])
+}
+
+func setValue(fv reflect.Value, s string) error {
+	if fv.Type() == timeType {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", s); err != nil {
+				return errors.New("must be a date")
+			}
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("must be a non-negative integer")
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		fv.SetFloat(f)
+	default:
+		return errors.New("unsupported field type " + fv.Type().String())
+	}
+	return nil
+}
+
+//validateStruct按validate标签校验v，支持的规则：
+//  required  不能为零值
+//  min=n     数字不小于n；字符串、切片的长度不小于n
+//  max=n     数字不大于n；字符串、切片的长度不大于n
+//  oneof=a b 只能是列出的值之一
+//nameTag为"json"时错误中的字段名取自json标签，否则依次取path、form、query标签
+func validateStruct(v interface{}, nameTag string) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	e := bindError(StatusBadRequest, "validation failed")
+	validateFields(rv, nameTag, "", e)
+	if len(e.Fields) > 0 {
+		return e
+	}
+	return nil
+}
+
+func validateFields(rv reflect.Value, nameTag, prefix string, e *BindError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name := prefix + fieldName(sf, nameTag)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != timeType {
+			if sf.Anonymous {
+				validateFields(fv, nameTag, prefix, e)
+			} else {
+				validateFields(fv, nameTag, name+".", e)
+			}
+			continue
+		}
+		rules := sf.Tag.Get("validate")
+		if rules == "" {
+			continue
+		}
+		for _, rule := range strings.Split(rules, ",") {
+			if msg := checkRule(fv, strings.TrimSpace(rule)); msg != "" {
+				e.Fields = append(e.Fields, FieldError{Field: name, Message: msg})
+				break
+			}
+		}
+	}
+}
+
+func fieldName(sf reflect.StructField, nameTag string) string {
+	tags := []string{"path", "form", "query"}
+	if nameTag != "" {
+		tags = []string{nameTag}
+	}
+	for _, tag := range tags {
+		name := strings.Split(sf.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+//checkRule返回校验失败的原因，校验通过时返回""
+func checkRule(fv reflect.Value, rule string) string {
+	name, arg := rule, ""
+	if index := strings.IndexByte(rule, '='); index != -1 {
+		name, arg = rule[:index], rule[index+1:]
+	}
+	switch name {
+	case "required":
+		if fv.IsZero() {
+			return "is required"
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return "invalid rule " + rule
+		}
+		n, isLen, ok := measure(fv)
+		if !ok {
+			return ""
+		}
+		if name == "min" && n < limit {
+			if isLen {
+				return "length must be at least " + arg
+			}
+			return "must be at least " + arg
+		}
+		if name == "max" && n > limit {
+			if isLen {
+				return "length must be at most " + arg
+			}
+			return "must be at most " + arg
+		}
+	case "oneof":
+		s := valueString(fv)
+		for _, option := range strings.Fields(arg) {
+			if s == option {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(arg), ", ")
+	default:
+		return "unknown rule " + name
+	}
+	return ""
+}
+
+//measure返回数字的值或者字符串、切片、map的长度
+func measure(fv reflect.Value) (n float64, isLen bool, ok bool) {
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false, true
+	}
+	return 0, false, false
+}
+
+func valueString(fv reflect.Value) string {
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10)
+	}
+	return ""
+}
@@ -2,11 +2,13 @@ package httpd
 
 import (
 	"bufio"
-	"errors"
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type chunkReader struct {
@@ -16,9 +18,31 @@ type chunkReader struct {
 	//利用done来记录报文主体是否读取完毕
 	done bool
 	crlf [2]byte //用来读取\r\n
+	//最后一个块之后的trailer会写入这里，即Request.Trailer
+	trailer Header
+	strict  bool
+	//读取trailer时用来限制其长度，即conn.lr
+	lr *io.LimitedReader
+	//第一次出错后报文的边界已经无法确定，之后的Read都返回这个错误，
+	//不能把后面的数据当作块大小继续解析
+	err error
 }
 
 func (cw *chunkReader) Read(p []byte) (n int, err error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err = cw.read(p)
+	if err == io.EOF && !cw.done {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && err != io.EOF {
+		cw.err = err
+	}
+	return
+}
+
+func (cw *chunkReader) read(p []byte) (n int, err error) {
 	if cw.done {
 		return 0, io.EOF
 	}
@@ -30,7 +54,7 @@ func (cw *chunkReader) Read(p []byte) (n int, err error) {
 	}
 	if cw.n == 0 {
 		cw.done = true
-		err = cw.discardCRLF()
+		err = cw.readTrailer()
 		return
 	}
 
@@ -38,10 +62,17 @@ func (cw *chunkReader) Read(p []byte) (n int, err error) {
 	if len(p) <= cw.n {
 		n, err = cw.bufr.Read(p)
 		cw.n -= n
+		//当前块恰好读完，同样需要消费掉块末尾的\r\n
+		if err == nil && cw.n == 0 {
+			err = cw.discardCRLF()
+		}
 		return n, err
 	}
 	//如果当前块剩余的数据不够p的长度
-	n, _ = io.ReadFull(cw.bufr, p[:cw.n])
+	n, err = io.ReadFull(cw.bufr, p[:cw.n])
+	if err != nil {
+		return
+	}
 	cw.n = 0
 	//将\r\n从流中消费掉
 	if err = cw.discardCRLF(); err != nil {
@@ -53,17 +84,73 @@ func (cw *chunkReader) Read(p []byte) (n int, err error) {
 func (cw *chunkReader) discardCRLF() (err error) {
 	if _, err = io.ReadFull(cw.bufr, cw.crlf[:]); err == nil {
 		if cw.crlf[0] != '\r' || cw.crlf[1] != '\n' {
-			return errors.New("unsupported encoding format of chunk")
+			return badRequest("unsupported encoding format of chunk")
 		}
 	}
 	return
 }
 
+//最后一个块(0\r\n)之后是若干个trailer首部，以空行结尾。
+//trailer与请求首部一样最多maxHeaderBytes字节
+func (cw *chunkReader) readTrailer() error {
+	if cw.lr != nil {
+		cw.lr.N = maxHeaderBytes
+		defer func() { cw.lr.N = noLimit }()
+	}
+	trailer, err := readHeader(cw.bufr, cw.strict)
+	if cw.lr != nil && cw.lr.N <= 0 {
+		return &statusError{code: statusRequestHeaderFieldsTooLarge, text: "trailer too large"}
+	}
+	if err != nil {
+		return err
+	}
+	for k, v := range trailer {
+		if forbiddenTrailer[k] {
+			continue
+		}
+		if cw.trailer != nil {
+			cw.trailer[k] = v
+		}
+	}
+	return nil
+}
+
+//这些首部影响报文的解析与路由，不允许出现在trailer中
+var forbiddenTrailer = map[string]bool{
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Content-Encoding":  true,
+	"Content-Range":     true,
+	"Transfer-Encoding": true,
+	"Trailer":           true,
+	"Host":              true,
+	"Authorization":     true,
+	"Expect":            true,
+}
+
+//块大小所在行的最大长度，chunk-ext可以任意长，必须加以限制
+const maxChunkLineBytes = 4 << 10
+
+//块大小所在行的格式为：chunk-size [ ";" chunk-ext ]，我们不使用chunk-ext，直接忽略
 func (cw *chunkReader) getChunkSize() (chunkSize int, err error) {
-	line, err := readLine(cw.bufr)
+	line, err := readLineLimit(cw.bufr, maxChunkLineBytes)
+	if err == errLineTooLong {
+		return 0, badRequest("chunk size line too long")
+	}
 	if err != nil {
 		return
 	}
+	if index := bytes.IndexByte(line, ';'); index != -1 {
+		line = line[:index]
+	}
+	line = bytes.TrimRight(line, " \t")
+	if len(line) == 0 {
+		return 0, badRequest("missing chunk size")
+	}
+	//超过15位的16进制数可能溢出int64
+	if len(line) > 15 {
+		return 0, badRequest("chunk size too large")
+	}
 	//将16进制换算成10进制
 	for i := 0; i < len(line); i++ {
 		switch {
@@ -74,7 +161,7 @@ func (cw *chunkReader) getChunkSize() (chunkSize int, err error) {
 		case '0' <= line[i] && line[i] <= '9':
 			chunkSize = chunkSize*16 + int(line[i]-'0')
 		default:
-			return 0, errors.New("illegal hex number")
+			return 0, badRequest("illegal hex number")
 		}
 	}
 	return
@@ -89,14 +176,18 @@ type chunkWriter struct {
 //因此只有在handler结束后调用bufw.Flush，或者在Handler结束前累计写入超过4096B的数据，
 //才会触发chunkWriter的Write方法。我们通过handlerDone来区分这两种情况。
 func (cw *chunkWriter) Write(p []byte) (n int, err error) {
-	if !cw.wrote {
-		cw.finalizeHeader(p)
-		if err = cw.writeHeader(); err != nil {
-			return
-		}
-		cw.wrote = true
+	if err = cw.writeHeaderOnce(p); err != nil {
+		return
+	}
+	bufw := cw.resp.w
+	//长度为0的块代表报文主体的结束，不能写出
+	if len(p) == 0 {
+		return 0, nil
+	}
+	//HEAD的响应只有首部，handler写入的数据只用来计算Content-Length
+	if cw.resp.req.Method == "HEAD" {
+		return len(p), nil
 	}
-	bufw := cw.resp.c.bufw
 	//当Write数据超过缓存容量时，利用chunk编码传输
 	if cw.resp.chunking {
 		_, err = fmt.Fprintf(bufw, "%x\r\n", len(p))
@@ -111,39 +202,122 @@ func (cw *chunkWriter) Write(p []byte) (n int, err error) {
 	return n, err
 }
 
+//writeHeaderOnce在第一次调用时根据p补全首部并写出状态行和首部
+func (cw *chunkWriter) writeHeaderOnce(p []byte) (err error) {
+	if cw.wrote {
+		return nil
+	}
+	cw.finalizeHeader(p)
+	if err = cw.writeHeader(); err != nil {
+		return
+	}
+	cw.wrote = true
+	return nil
+}
+
 func (cw *chunkWriter) finalizeHeader(p []byte) {
 	header := cw.resp.header
-	if header.Get("Content-Type") == "" {
+	cw.resp.declareTrailers()
+	//1xx、204和304响应没有报文主体，也就不需要分帧首部。
+	//304的Content-Length表示的是对应的200响应的长度，由handler自己决定是否设置
+	if !bodyAllowedForStatus(cw.resp.statusCode) {
+		header.Del("Transfer-Encoding")
+		if cw.resp.statusCode != StatusNotModified {
+			header.Del("Content-Length")
+		}
+		return
+	}
+	isHEAD := cw.resp.req.Method == "HEAD"
+	if header.Get("Content-Type") == "" && len(p) > 0 {
 		header.Set("Content-Type", http.DetectContentType(p))
 	}
+	//声明了trailer的响应必须以chunk编码传输，HTTP/1.0则只能丢弃trailer
+	hasTrailers := len(cw.resp.trailers) > 0 && cw.resp.req.ProtoAtLeast(1, 1) && !isHEAD
 	if header.Get("Content-Length") == "" && header.Get("Transfer-Encoding") == "" {
-		if cw.resp.handlerDone {
-			buffered := cw.resp.bufw.Buffered()
-			header.Set("Content-Length", strconv.Itoa(buffered))
-		} else {
+		buffered := cw.resp.bufw.Buffered()
+		if cw.resp.handlerDone && !hasTrailers {
+			//HEAD的handler可能根本不写报文主体，此时我们不知道对应GET响应的长度
+			if !isHEAD || buffered > 0 {
+				header.Set("Content-Length", strconv.Itoa(buffered))
+			}
+		} else if isHEAD {
+			//HEAD的响应没有报文主体，长度未知时也不需要chunk编码或者关闭连接
+		} else if cw.resp.req.ProtoAtLeast(1, 1) {
 			cw.resp.chunking = true
 			header.Set("Transfer-Encoding", "chunked")
+		} else {
+			//HTTP/1.0不支持chunk编码，只能以关闭连接来标识报文主体的结束
+			cw.resp.closeAfterReply = true
 		}
 		return
 	}
-	if header.Get("Transfer-Encoding") == "chunked" {
+	if header.Get("Transfer-Encoding") == "chunked" && !isHEAD {
 		cw.resp.chunking = true
 	}
 }
 
+//setupConnectionHeader让响应的Connection首部与是否关闭连接保持一致。
+//handler主动设置了Connection: close时同样关闭连接。
+//我们只增删close和keep-alive，handler设置的其他选项(如101响应中的Upgrade)原样保留
+func (cw *chunkWriter) setupConnectionHeader() {
+	resp := cw.resp
+	header := resp.header
+	if hasToken(header["Connection"], "close") {
+		resp.closeAfterReply = true
+	}
+	var tokens []string
+	for _, v := range header["Connection"] {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" || strings.EqualFold(t, "close") || strings.EqualFold(t, "keep-alive") {
+				continue
+			}
+			tokens = append(tokens, t)
+		}
+	}
+	if resp.closeAfterReply {
+		tokens = append(tokens, "close")
+	} else if !resp.req.ProtoAtLeast(1, 1) {
+		tokens = append(tokens, "keep-alive")
+	}
+	if len(tokens) == 0 {
+		header.Del("Connection")
+		return
+	}
+	header.Set("Connection", strings.Join(tokens, ", "))
+}
+
+//setupDateServerHeader补全Date和Server首部。handler设置过的首部保持不变，
+//handler可以通过将对应的key设置为nil来禁止发送，如w.Header()["Date"] = nil
+func (cw *chunkWriter) setupDateServerHeader() {
+	header := cw.resp.header
+	if _, ok := header["Date"]; !ok {
+		header.Set("Date", httpDate(time.Now()))
+	}
+	if _, ok := header["Server"]; !ok && cw.resp.c.svr.ServerHeader != "" {
+		header.Set("Server", cw.resp.c.svr.ServerHeader)
+	}
+}
+
 func (cw *chunkWriter) writeHeader() (err error) {
+	//handler没有读Body就回复了，客户端可能还会发送报文主体，也可能不会，
+	//我们无法确定下一个请求从哪里开始，只能关闭连接
+	if expect := cw.resp.req.expect; expect != nil && !expect.wroteContinue {
+		expect.finalSent = true
+		cw.resp.closeAfterReply = true
+	}
+	cw.resp.setupTrailerHeader()
+	cw.setupConnectionHeader()
+	cw.setupDateServerHeader()
 	codeString := strconv.Itoa(cw.resp.statusCode)
 	statusLine := cw.resp.req.Proto + " " + codeString + " " + statusText[cw.resp.statusCode] + "\r\n"
-	bufw := cw.resp.c.bufw
+	bufw := cw.resp.w
 	_, err = bufw.WriteString(statusLine)
 	if err != nil {
 		return
 	}
-	for key, value := range cw.resp.header {
-		_, err = bufw.WriteString(key + ": " + value[0] + "\r\n")
-		if err != nil {
-			return
-		}
+	if err = cw.resp.header.writeSubset(bufw, cw.resp.excludeHeader()); err != nil {
+		return
 	}
 	_, err = bufw.WriteString("\r\n")
 	return
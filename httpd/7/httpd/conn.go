@@ -8,16 +8,26 @@ import (
 	"net"
 )
 
+//请求行与首部的最大长度
+const maxHeaderBytes = 1 << 20
+
+//读取报文主体时lr不再限制长度，由报文自身的边界和MaxRequestBodyBytes限制
+const noLimit = (1 << 63) - 1
+
 type conn struct {
 	svr  *Server
 	rwc  net.Conn
 	lr   *io.LimitedReader
 	bufr *bufio.Reader
 	bufw *bufio.Writer
+	//并发处理中的流水线请求，没有时为nil
+	pipe *pipeline
+	//连接被handler接管后，我们不再读写和关闭rwc
+	hijacked bool
 }
 
 func newConn(rwc net.Conn, svr *Server) *conn {
-	lr := &io.LimitedReader{R: rwc, N: 1 << 20}
+	lr := &io.LimitedReader{R: rwc, N: maxHeaderBytes}
 	return &conn{
 		svr:  svr,
 		rwc:  rwc,
@@ -32,6 +42,7 @@ func (c *conn) serve() {
 		if err := recover(); err != nil {
 			log.Printf("panic recoverred,err:%v\n", err)
 		}
+		c.finishPipeline()
 		c.close()
 	}()
 	//http1.1支持keep-alive长连接，所以一个连接中可能读出
@@ -39,11 +50,34 @@ func (c *conn) serve() {
 	for {
 		req, err := c.readRequest()
 		if err != nil {
-			handleErr(err, c)
+			//流水线中的响应要求关闭连接时，读请求的错误是我们主动造成的
+			if c.finishPipeline() {
+				c.handleErr(err)
+			}
+			return
+		}
+		if c.svr.Pipelining && req.pipelinable() {
+			if c.pipe == nil {
+				c.pipe = newPipeline(c)
+			}
+			if !c.pipe.add(req) {
+				return
+			}
+			continue
+		}
+		//串行处理前，先保证之前的响应都已经写回
+		if !c.finishPipeline() {
 			return
 		}
 		resp := c.setupResponse(req)
+		req.wrapBody(resp)
 		c.svr.Handler.ServeHTTP(resp, req)
+		if c.hijacked {
+			if req.multipartForm != nil {
+				req.multipartForm.RemoveAll()
+			}
+			return
+		}
 		if err = req.finishRequest(resp); err != nil {
 			return
 		}
@@ -61,13 +95,52 @@ func (c *conn) setupResponse(req *Request) *response {
 	return setupResponse(c, req)
 }
 
+//finishPipeline等待流水线中的响应全部写回，返回false代表连接需要关闭
+func (c *conn) finishPipeline() bool {
+	if c.pipe == nil {
+		return true
+	}
+	ok := c.pipe.wait()
+	c.pipe = nil
+	return ok
+}
+
 func (c *conn) close() {
+	if c.hijacked {
+		return
+	}
 	c.rwc.Close()
 }
 
-func handleErr(err error, c *conn) {
+func (c *conn) handleErr(err error) {
+	//请求首部超出了lr的限制
+	if c.lr.N <= 0 {
+		err = &statusError{code: statusRequestHeaderFieldsTooLarge, text: "request header too large"}
+	}
+	if se, ok := err.(*statusError); ok {
+		c.replyError(se.code)
+		return
+	}
 	if err == io.EOF {
 		return
 	}
 	fmt.Println("handleErr:err=", err)
 }
+
+//在请求无法解析时回复错误，此时没有可用的Request，我们伪造一个，
+//回复完毕后关闭连接
+func (c *conn) replyError(code int) {
+	req := &Request{
+		Method:     "GET",
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(Header),
+		Body:       &eofReader{},
+		conn:       c,
+	}
+	resp := c.setupResponse(req)
+	resp.closeAfterReply = true
+	Error(resp, req, code)
+	req.finishRequest(resp)
+}
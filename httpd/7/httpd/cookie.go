@@ -0,0 +1,174 @@
+package httpd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SameSite int
+
+const (
+	SameSiteDefaultMode SameSite = iota
+	SameSiteLaxMode
+	SameSiteStrictMode
+	SameSiteNoneMode
+)
+
+//Cookie既用于解析请求中的Cookie首部，也用于生成响应中的Set-Cookie首部。
+//解析请求时只有Name和Value有意义
+type Cookie struct {
+	Name  string
+	Value string
+
+	Path    string
+	Domain  string
+	Expires time.Time //零值代表不设置
+	//MaxAge为0代表不设置，小于0代表立即删除，即Max-Age=0
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite SameSite
+}
+
+var ErrNoCookie = errors.New("http: named cookie not present")
+
+//RFC 6265 section 4.1.1中的cookie-octet，不含空格、'"'、','、';'和'\'
+func isCookieOctet(c byte) bool {
+	return c == 0x21 || 0x23 <= c && c <= 0x2b || 0x2d <= c && c <= 0x3a ||
+		0x3c <= c && c <= 0x5b || 0x5d <= c && c <= 0x7e
+}
+
+func validCookieName(name string) bool {
+	return validHeaderKey(name)
+}
+
+//value两端可以带一对双引号
+func validCookieValue(value string) bool {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	for i := 0; i < len(value); i++ {
+		if !isCookieOctet(value[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+//Path和Domain中不能出现控制字符和';'，否则会破坏Set-Cookie的结构
+func validCookieAttr(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if v[i] < 0x20 || v[i] == 0x7f || v[i] == ';' {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Cookie) Valid() error {
+	if !validCookieName(c.Name) {
+		return errors.New("http: invalid cookie name " + strconv.Quote(c.Name))
+	}
+	if !validCookieValue(c.Value) {
+		return errors.New("http: invalid cookie value for " + c.Name)
+	}
+	if !validCookieAttr(c.Path) {
+		return errors.New("http: invalid cookie path for " + c.Name)
+	}
+	if !validCookieAttr(c.Domain) {
+		return errors.New("http: invalid cookie domain for " + c.Name)
+	}
+	return nil
+}
+
+//String返回Set-Cookie首部的值，cookie不合法时返回""
+func (c *Cookie) String() string {
+	if c.Valid() != nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(c.Name + "=" + c.Value)
+	if c.Path != "" {
+		b.WriteString("; Path=" + c.Path)
+	}
+	if c.Domain != "" {
+		b.WriteString("; Domain=" + strings.TrimPrefix(c.Domain, "."))
+	}
+	if !c.Expires.IsZero() {
+		b.WriteString("; Expires=" + c.Expires.UTC().Format(TimeFormat))
+	}
+	if c.MaxAge > 0 {
+		b.WriteString("; Max-Age=" + strconv.Itoa(c.MaxAge))
+	} else if c.MaxAge < 0 {
+		b.WriteString("; Max-Age=0")
+	}
+	if c.HttpOnly {
+		b.WriteString("; HttpOnly")
+	}
+	if c.Secure {
+		b.WriteString("; Secure")
+	}
+	switch c.SameSite {
+	case SameSiteLaxMode:
+		b.WriteString("; SameSite=Lax")
+	case SameSiteStrictMode:
+		b.WriteString("; SameSite=Strict")
+	case SameSiteNoneMode:
+		b.WriteString("; SameSite=None")
+	}
+	return b.String()
+}
+
+//Set-Cookie中Expires使用的时间格式
+const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+//SetCookie给响应添加一个Set-Cookie首部，cookie不合法时返回错误且不做任何修改
+func SetCookie(w ResponseWriter, c *Cookie) error {
+	if err := c.Valid(); err != nil {
+		return err
+	}
+	w.Header().Add("Set-Cookie", c.String())
+	return nil
+}
+
+//Cookie首部的格式为"name1=value1; name2=value2"，不合法的键值对会被忽略，
+//同名的cookie全部保留
+func (r *Request) parseCookies() {
+	r.parsedCookies = true
+	for _, line := range r.Header["Cookie"] {
+		for _, pair := range strings.Split(line, ";") {
+			pair = strings.TrimSpace(pair)
+			index := strings.IndexByte(pair, '=')
+			if index == -1 {
+				continue
+			}
+			name, value := strings.TrimSpace(pair[:index]), strings.TrimSpace(pair[index+1:])
+			if !validCookieName(name) || !validCookieValue(value) {
+				continue
+			}
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				value = value[1 : len(value)-1]
+			}
+			r.cookies = append(r.cookies, &Cookie{Name: name, Value: value})
+		}
+	}
+}
+
+func (r *Request) Cookies() []*Cookie {
+	if !r.parsedCookies {
+		r.parseCookies()
+	}
+	return r.cookies
+}
+
+//Cookie返回第一个名为name的cookie，不存在时返回ErrNoCookie
+func (r *Request) Cookie(name string) (*Cookie, error) {
+	for _, c := range r.Cookies() {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, ErrNoCookie
+}
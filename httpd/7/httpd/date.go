@@ -0,0 +1,26 @@
+package httpd
+
+import (
+	"sync"
+	"time"
+)
+
+//每个响应都要带上Date首部，格式化时间的开销不小，
+//而Date的精度只到秒，同一秒内的响应共用一个缓存的值
+var dateCache struct {
+	mu    sync.Mutex
+	sec   int64
+	value string
+}
+
+//httpDate返回now所在秒的HTTP-date，如"Mon, 02 Jan 2006 15:04:05 GMT"
+func httpDate(now time.Time) string {
+	sec := now.Unix()
+	dateCache.mu.Lock()
+	defer dateCache.mu.Unlock()
+	if sec != dateCache.sec || dateCache.value == "" {
+		dateCache.sec = sec
+		dateCache.value = now.UTC().Format(TimeFormat)
+	}
+	return dateCache.value
+}
@@ -0,0 +1,106 @@
+package httpd
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+)
+
+//解压后的报文主体默认最多32MB，防止压缩炸弹
+const defaultMaxDecompressedBytes = 32 << 20
+
+//checkContentEncoding检查报文主体的压缩格式，只支持gzip和deflate，
+//其余的编码回复415(RFC 7231 section 3.1.2.2)
+func (r *Request) checkContentEncoding() error {
+	ce, ok := r.Header["Content-Encoding"]
+	if !ok {
+		return nil
+	}
+	if len(ce) != 1 || strings.Contains(ce[0], ",") {
+		return &statusError{code: StatusUnsupportedMediaType, text: "multiple content codings"}
+	}
+	switch encoding := strings.ToLower(strings.TrimSpace(ce[0])); encoding {
+	case "identity":
+	case "gzip", "x-gzip", "deflate":
+		r.contentEncoding = encoding
+	default:
+		return &statusError{code: StatusUnsupportedMediaType, text: "unsupported content coding " + encoding}
+	}
+	return nil
+}
+
+//wrapBody在handler执行前包装Body：先限制报文主体的长度，再透明解压。
+//解压后的长度未知，所以删除Content-Encoding，并将ContentLength置为-1
+func (r *Request) wrapBody(resp *response) {
+	svr := r.conn.svr
+	if _, ok := r.Body.(*eofReader); !ok {
+		r.Body = &wireBodyReader{r: r.Body, resp: resp}
+	}
+	if svr.MaxRequestBodyBytes > 0 {
+		r.Body = MaxBytesReader(resp, r.Body, svr.MaxRequestBodyBytes)
+		//报文主体注定超出限制，handler不读Body时剩余的数据也无法读完，
+		//必须在写出响应首部之前就决定关闭连接
+		if r.ContentLength > svr.MaxRequestBodyBytes {
+			resp.closeAfterReply = true
+		}
+	}
+	r.wireBody = r.Body
+	if r.contentEncoding == "" {
+		return
+	}
+	limit := svr.MaxDecompressedBodyBytes
+	if limit == 0 {
+		limit = defaultMaxDecompressedBytes
+	}
+	var body io.Reader = &decompressReader{r: r.Body, encoding: r.contentEncoding}
+	if limit > 0 {
+		//解压后超出限制不影响报文的边界，所以不需要关闭连接
+		body = MaxBytesReader(nil, body, limit)
+	}
+	r.Body = body
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+}
+
+//decompressReader在第一次Read时才创建解压器，因为gzip.NewReader会立即读取gzip头部，
+//过早创建会触发100 Continue
+type decompressReader struct {
+	r        io.Reader
+	encoding string
+	zr       io.Reader
+	err      error
+}
+
+func (d *decompressReader) Read(p []byte) (n int, err error) {
+	if d.zr == nil && d.err == nil {
+		d.zr, d.err = newDecompressor(d.r, d.encoding)
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.zr.Read(p)
+}
+
+func newDecompressor(r io.Reader, encoding string) (io.Reader, error) {
+	if encoding != "deflate" {
+		return gzip.NewReader(r)
+	}
+	//HTTP中的deflate应当是zlib格式(RFC 7230 section 4.2.2)，
+	//但有不少客户端发送的是不带zlib头部的原始deflate数据，我们根据头部区分
+	bufr := bufio.NewReader(r)
+	header, err := bufr.Peek(2)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(bufr)
+	}
+	return flate.NewReader(bufr), nil
+}
@@ -0,0 +1,115 @@
+package httpd
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+//ErrorPages是按状态码注册的错误页面，Server回复错误时(包括解析请求失败)都会先查找这里
+type ErrorPages struct {
+	mu    sync.RWMutex
+	pages map[int]*errorPage
+}
+
+type errorPage struct {
+	//模板和静态内容二选一
+	tmpl        *template.Template
+	content     []byte
+	contentType string
+}
+
+//渲染错误页面模板时传入的数据
+type ErrorPageData struct {
+	StatusCode int
+	StatusText string
+	Method     string
+	Path       string
+}
+
+func NewErrorPages() *ErrorPages {
+	return &ErrorPages{pages: make(map[int]*errorPage)}
+}
+
+func (ep *ErrorPages) set(code int, page *errorPage) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if ep.pages == nil {
+		ep.pages = make(map[int]*errorPage)
+	}
+	ep.pages[code] = page
+}
+
+//SetTemplate注册一个html模板，模板中可以使用ErrorPageData的字段
+func (ep *ErrorPages) SetTemplate(code int, text string) error {
+	tmpl, err := template.New(strconv.Itoa(code)).Parse(text)
+	if err != nil {
+		return err
+	}
+	ep.set(code, &errorPage{tmpl: tmpl, contentType: "text/html; charset=utf-8"})
+	return nil
+}
+
+//SetFile注册一个静态文件，文件内容在注册时一次性读入内存
+func (ep *ErrorPages) SetFile(code int, filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	ep.set(code, &errorPage{
+		content:     content,
+		contentType: mime.TypeByExtension(filepath.Ext(filename)),
+	})
+	return nil
+}
+
+func (ep *ErrorPages) render(w ResponseWriter, r *Request, code int) bool {
+	ep.mu.RLock()
+	page, ok := ep.pages[code]
+	ep.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	content := page.content
+	if page.tmpl != nil {
+		data := &ErrorPageData{StatusCode: code, StatusText: statusText[code]}
+		if r != nil {
+			data.Method = r.Method
+			if r.URL != nil {
+				data.Path = r.URL.Path
+			}
+		}
+		var buff bytes.Buffer
+		if err := page.tmpl.Execute(&buff, data); err != nil {
+			return false
+		}
+		content = buff.Bytes()
+	}
+	if page.contentType != "" {
+		w.Header().Set("Content-Type", page.contentType)
+	}
+	w.WriteHeader(code)
+	w.Write(content)
+	return true
+}
+
+//Error回复code对应的错误页面，如果Server没有注册该状态码的页面，则回复纯文本
+func Error(w ResponseWriter, r *Request, code int) {
+	if r != nil && r.conn != nil && r.conn.svr.ErrorPages != nil {
+		if r.conn.svr.ErrorPages.render(w, r, code) {
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	io.WriteString(w, strconv.Itoa(code)+" "+statusText[code]+"\n")
+}
+
+func NotFound(w ResponseWriter, r *Request) {
+	Error(w, r, StatusNotFound)
+}
@@ -0,0 +1,159 @@
+package httpd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/url"
+)
+
+//SemicolonMode决定urlencoded数据中';'的含义
+type SemicolonMode int
+
+const (
+	//';'作为普通字符保留在key或value中
+	SemicolonLiteral SemicolonMode = iota
+	//';'与'&'一样作为键值对的分隔符，HTML 4曾经推荐这种写法
+	SemicolonSeparator
+	//遇到未编码的';'时返回ErrFormSemicolon，
+	//前置代理把';'当作分隔符时，这样可以避免两边解析出不同的参数
+	SemicolonReject
+)
+
+var (
+	ErrFormTooLarge    = errors.New("http: form too large")
+	ErrFormTooManyKeys = errors.New("http: too many form keys")
+	ErrFormFieldLarge  = errors.New("http: form field too large")
+	ErrFormSemicolon   = errors.New("http: unescaped semicolon in form")
+)
+
+//FormOptions控制application/x-www-form-urlencoded数据的解析，
+//各个限制为0时使用默认值，小于0时不限制
+type FormOptions struct {
+	//所有数据的最大长度，默认10MB
+	MaxBytes int64
+	//键值对的最大数量，默认1000
+	MaxKeys int
+	//单个key或者value解码前的最大长度，默认1MB
+	MaxFieldBytes int
+	Semicolon     SemicolonMode
+}
+
+const (
+	defaultMaxFormBytes      = 10 << 20
+	defaultMaxFormKeys       = 1000
+	defaultMaxFormFieldBytes = 1 << 20
+)
+
+func (opts FormOptions) withDefaults() FormOptions {
+	if opts.MaxBytes == 0 {
+		opts.MaxBytes = defaultMaxFormBytes
+	}
+	if opts.MaxKeys == 0 {
+		opts.MaxKeys = defaultMaxFormKeys
+	}
+	if opts.MaxFieldBytes == 0 {
+		opts.MaxFieldBytes = defaultMaxFormFieldBytes
+	}
+	return opts
+}
+
+//ParseFormReader从r中流式地解析"a=1&b=2"形式的数据，内存占用只与单个键值对的长度有关。
+//'+'解码为空格，%XX按百分号编码解码，空的key和value都会保留。
+//百分号编码非法的键值对会被跳过，并在最后返回第一个*url.EscapeError；
+//超出限制、遇到被拒绝的';'或者读取出错时立即返回，此时values只包含已经解析出的部分
+func ParseFormReader(r io.Reader, opts FormOptions) (values Values, err error) {
+	opts = opts.withDefaults()
+	values = make(Values)
+	bufr, ok := r.(io.ByteReader)
+	if !ok {
+		bufr = bufio.NewReader(r)
+	}
+	var (
+		field    []byte
+		key      []byte
+		inValue  bool
+		total    int64
+		keys     int
+		firstErr error
+	)
+	//emit在一个键值对结束时调用
+	emit := func() error {
+		if !inValue && len(field) == 0 {
+			return nil
+		}
+		if opts.MaxKeys > 0 && keys >= opts.MaxKeys {
+			return ErrFormTooManyKeys
+		}
+		keys++
+		var k, v string
+		if inValue {
+			k, v = string(key), string(field)
+		} else {
+			k = string(field)
+		}
+		k, err := url.QueryUnescape(k)
+		if err == nil {
+			v, err = url.QueryUnescape(v)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			values.Add(k, v)
+		}
+		field, key, inValue = field[:0], key[:0], false
+		return nil
+	}
+	for {
+		c, err := bufr.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return values, err
+		}
+		total++
+		if opts.MaxBytes > 0 && total > opts.MaxBytes {
+			return values, ErrFormTooLarge
+		}
+		switch {
+		case c == '&' || c == ';' && opts.Semicolon == SemicolonSeparator:
+			if err = emit(); err != nil {
+				return values, err
+			}
+			continue
+		case c == ';' && opts.Semicolon == SemicolonReject:
+			return values, ErrFormSemicolon
+		case c == '=' && !inValue:
+			key, field, inValue = append(key, field...), field[:0], true
+			continue
+		}
+		if opts.MaxFieldBytes > 0 && len(field) >= opts.MaxFieldBytes {
+			return values, ErrFormFieldLarge
+		}
+		field = append(field, c)
+	}
+	if err = emit(); err != nil {
+		return values, err
+	}
+	return values, firstErr
+}
+
+//formOptions返回Server设置的表单解析选项
+func (r *Request) formOptions() FormOptions {
+	if r.conn == nil {
+		return FormOptions{}
+	}
+	return r.conn.svr.FormOptions
+}
+
+func (r *Request) parsePostForm() (err error) {
+	r.postForm, err = ParseFormReader(r.Body, r.formOptions())
+	//个别键值对编码错误不影响其余的值
+	if _, ok := err.(url.EscapeError); ok {
+		err = nil
+	}
+	return
+}
@@ -1,24 +1,159 @@
 package httpd
 
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+//Header的key统一使用CanonicalHeaderKey规范化后的形式，
+//直接操作map时需要自行保证这一点
 type Header map[string][]string
 
 func (h Header) Add(key, value string) {
+	key = CanonicalHeaderKey(key)
 	h[key] = append(h[key], value)
 }
 
 func (h Header) Set(key, value string) {
-	h[key] = []string{value}
+	h[CanonicalHeaderKey(key)] = []string{value}
 }
 
 //Get获取key对应的第一个value，如果不存在对应的key，则return ""
 func (h Header) Get(key string) string {
-	if value, ok := h[key]; ok && len(value) > 0 {
+	if value, ok := h[CanonicalHeaderKey(key)]; ok && len(value) > 0 {
 		return value[0]
 	} else {
 		return ""
 	}
 }
 
+//Values返回key对应的所有value，返回的切片与Header共享底层数组
+func (h Header) Values(key string) []string {
+	return h[CanonicalHeaderKey(key)]
+}
+
 func (h Header) Del(key string) {
-	delete(h, key)
+	delete(h, CanonicalHeaderKey(key))
+}
+
+func (h Header) Clone() Header {
+	if h == nil {
+		return nil
+	}
+	h2 := make(Header, len(h))
+	for k, v := range h {
+		if v == nil {
+			h2[k] = nil
+			continue
+		}
+		h2[k] = append([]string(nil), v...)
+	}
+	return h2
+}
+
+var headerNewlineReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+//这些首部的值是以逗号分隔的列表(RFC 7230 section 3.2.2)，多个值可以合并到一行。
+//其余首部，特别是值中可能含有逗号的Set-Cookie，每个值单独一行
+var commaListHeaders = map[string]bool{
+	"Accept":            true,
+	"Accept-Charset":    true,
+	"Accept-Encoding":   true,
+	"Accept-Language":   true,
+	"Accept-Ranges":     true,
+	"Allow":             true,
+	"Cache-Control":     true,
+	"Connection":        true,
+	"Content-Encoding":  true,
+	"Content-Language":  true,
+	"Pragma":            true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"Vary":              true,
+	"Via":               true,
+}
+
+//Write以报文首部的格式写出所有的key-value，key按字典序排列，同一个key的多个value
+//保持添加时的顺序，逗号列表类型的首部合并为一行。
+//value中的换行符会被替换为空格，防止首部注入
+func (h Header) Write(w io.Writer) error {
+	return h.writeSubset(w, nil)
+}
+
+//writeSubset与Write相同，但跳过exclude中的key
+func (h Header) writeSubset(w io.Writer, exclude map[string]bool) error {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		if !exclude[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values := h[k]
+		if len(values) > 1 && commaListHeaders[k] {
+			values = []string{strings.Join(values, ", ")}
+		}
+		for _, v := range values {
+			v = headerNewlineReplacer.Replace(strings.TrimSpace(v))
+			if _, err := io.WriteString(w, k+": "+v+"\r\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//CanonicalHeaderKey将key转换为首字母以及'-'后的字母大写、其余字母小写的形式，
+//如"content-length"转换为"Content-Length"。包含非法字符的key原样返回
+func CanonicalHeaderKey(key string) string {
+	upper := true
+	canonical := true
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !isTokenChar(c) {
+			return key
+		}
+		if upper && 'a' <= c && c <= 'z' || !upper && 'A' <= c && c <= 'Z' {
+			canonical = false
+		}
+		upper = c == '-'
+	}
+	if canonical {
+		return key
+	}
+	b := []byte(key)
+	upper = true
+	for i, c := range b {
+		if upper && 'a' <= c && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+		} else if !upper && 'A' <= c && c <= 'Z' {
+			b[i] = c - 'A' + 'a'
+		}
+		upper = c == '-'
+	}
+	return string(b)
+}
+
+//hasToken判断以逗号分隔的首部值列表中是否包含token，不区分大小写
+func hasToken(values []string, token string) bool {
+	for _, v := range values {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//RFC 7230 section 3.2.6中的tchar
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) != -1
 }
@@ -0,0 +1,52 @@
+package httpd
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrBodyTooLarge = errors.New("http: request body too large")
+
+type maxBytesReader struct {
+	w   ResponseWriter
+	r   io.Reader
+	n   int64 //还允许读取的字节数
+	err error
+}
+
+//MaxBytesReader限制从r中最多读取n个字节，超出时返回ErrBodyTooLarge，
+//并让服务器在本次响应后关闭连接，因为剩余的报文主体不会再被读取。
+//handler在遇到ErrBodyTooLarge时一般应当回复413
+func MaxBytesReader(w ResponseWriter, r io.Reader, n int64) io.Reader {
+	if n < 0 {
+		n = 0
+	}
+	return &maxBytesReader{w: w, r: r, n: n}
+}
+
+func (l *maxBytesReader) Read(p []byte) (n int, err error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	//多读一个字节，用来区分恰好读完和超出限制两种情况。
+	//不能写成l.n+1，n为math.MaxInt64时会溢出
+	if int64(len(p))-1 > l.n {
+		p = p[:l.n+1]
+	}
+	n, err = l.r.Read(p)
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		l.err = err
+		return n, err
+	}
+	n = int(l.n)
+	l.n = 0
+	l.err = ErrBodyTooLarge
+	if resp, ok := l.w.(*response); ok {
+		resp.closeAfterReply = true
+	}
+	return n, l.err
+}
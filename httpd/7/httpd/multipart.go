@@ -114,7 +114,7 @@ func (mr *MultipartReader) readLine() ([]byte, error) {
 
 func (mr *MultipartReader) ReadForm() (mf *MultipartForm, err error) {
 	mf = &MultipartForm{
-		Value: make(map[string]string),
+		Value: make(Values),
 		File:  make(map[string]*FileHeader),
 	}
 	var part *Part
@@ -143,7 +143,7 @@ func (mr *MultipartReader) ReadForm() (mf *MultipartForm, err error) {
 			if nonFileMaxMemory < 0 {
 				return nil, errors.New("multipart: message too large")
 			}
-			mf.Value[part.FormName()] = buff.String()
+			mf.Value.Add(part.FormName(), buff.String())
 			continue
 		}
 		//file part
@@ -199,7 +199,7 @@ type Part struct {
 }
 
 func (p *Part) readHeader() (err error) {
-	p.Header, err = readHeader(p.mr.bufr)
+	p.Header, err = readHeader(p.mr.bufr, false)
 	return err
 }
 
@@ -286,7 +286,7 @@ func (p *Part) Close() error {
 }
 
 type MultipartForm struct {
-	Value map[string]string
+	Value Values
 	File  map[string]*FileHeader
 }
 
@@ -0,0 +1,227 @@
+package httpd
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//pattern的格式为"[METHOD ]/path"，如"GET /index"，不指定METHOD时匹配所有方法。
+//path中可以包含带约束的参数，如"/post/{id:int}"，写法见pathPattern
+type ServeMux struct {
+	//没有路由匹配时调用，为nil时使用NotFound
+	NotFound Handler
+	//路由匹配但方法不匹配时调用，为nil时回复405
+	MethodNotAllowed Handler
+
+	mu sync.RWMutex
+	//不含参数的路由
+	m map[string]*route
+	//含参数的路由，按优先级从高到低排列
+	params []*route
+}
+
+//同一个path下按方法区分的handler，方法为""代表匹配所有方法
+type route struct {
+	pp      *pathPattern
+	entries map[string]muxEntry
+}
+
+type muxEntry struct {
+	//用户注册时传入的原始pattern
+	pattern string
+	handler HandlerFunc
+	//同一个route下各方法的参数名可能不同，所以每个entry各自保存
+	pp *pathPattern
+}
+
+func NewServeMux() *ServeMux {
+	return &ServeMux{
+		m: make(map[string]*route),
+	}
+}
+
+//路由匹配时会忽略末尾的'/'，所以"/foo"与"/foo/"实际上是同一个路由
+func cleanPattern(pattern string) string {
+	if len(pattern) > 1 && pattern[len(pattern)-1] == '/' {
+		return pattern[:len(pattern)-1]
+	}
+	return pattern
+}
+
+func splitPattern(pattern string) (method, path string) {
+	index := strings.IndexByte(pattern, ' ')
+	if index == -1 {
+		return "", pattern
+	}
+	return pattern[:index], strings.TrimLeft(pattern[index+1:], " ")
+}
+
+func (rt *route) entry(method string) (muxEntry, bool) {
+	if e, ok := rt.entries[method]; ok {
+		return e, true
+	}
+	//HEAD请求可以交给GET的handler处理
+	if method == "HEAD" {
+		if e, ok := rt.entries["GET"]; ok {
+			return e, true
+		}
+	}
+	e, ok := rt.entries[""]
+	return e, ok
+}
+
+//match依次尝试静态路由和参数路由，返回第一个路径和方法都匹配的entry。
+//如果有路径匹配但方法都不匹配的路由，allow为这些路由支持的方法
+func (sm *ServeMux) match(r *Request) (e muxEntry, allow []string, found bool) {
+	path := cleanPattern(r.URL.Path)
+	pathMatched := false
+	if rt, ok := sm.m[path]; ok {
+		if e, ok := rt.entry(r.Method); ok {
+			return e, nil, true
+		}
+		pathMatched = true
+		allow = rt.methods(allow)
+	}
+	parts := strings.Split(path[1:], "/")
+	for _, rt := range sm.params {
+		if _, _, ok := rt.pp.match(parts); !ok {
+			continue
+		}
+		if e, ok := rt.entry(r.Method); ok {
+			r.params, r.paramValues, _ = e.pp.match(parts)
+			return e, nil, true
+		}
+		pathMatched = true
+		allow = rt.methods(allow)
+	}
+	if !pathMatched {
+		return e, nil, false
+	}
+	sort.Strings(allow)
+	return e, allow, false
+}
+
+func (rt *route) methods(dst []string) []string {
+	for method := range rt.entries {
+		dst = append(dst, method)
+	}
+	return dst
+}
+
+func (sm *ServeMux) ServeHTTP(w ResponseWriter, r *Request) {
+	if r.URL.Path == "" || r.URL.Path[0] != '/' {
+		Error(w, r, StatusBadRequest)
+		return
+	}
+	sm.mu.RLock()
+	e, allow, found := sm.match(r)
+	sm.mu.RUnlock()
+	switch {
+	case found:
+		e.handler(w, r)
+	case allow == nil && sm.NotFound != nil:
+		sm.NotFound.ServeHTTP(w, r)
+	case allow == nil:
+		NotFound(w, r)
+	default:
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		if sm.MethodNotAllowed != nil {
+			sm.MethodNotAllowed.ServeHTTP(w, r)
+			return
+		}
+		Error(w, r, StatusMethodNotAllowed)
+	}
+}
+
+//重复或者有歧义的pattern会直接panic，避免后注册的handler悄无声息地覆盖掉之前的
+func (sm *ServeMux) HandleFunc(pattern string, cb HandlerFunc) {
+	method, path := splitPattern(pattern)
+	if path == "" || path[0] != '/' {
+		panic("httpd: invalid pattern " + strconv.Quote(pattern))
+	}
+	if cb == nil {
+		panic("httpd: nil handler for pattern " + pattern)
+	}
+	pp, err := parsePathPattern(path)
+	if err != nil {
+		panic("httpd: invalid pattern " + strconv.Quote(pattern) + ": " + err.Error())
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	rt := sm.lookupRoute(pp)
+	if e, ok := rt.entries[method]; ok {
+		if e.pattern == pattern {
+			panic("httpd: multiple registrations for " + pattern)
+		}
+		panic("httpd: pattern " + pattern + " conflicts with registered pattern " + e.pattern)
+	}
+	rt.entries[method] = muxEntry{pattern: pattern, handler: cb, pp: pp}
+}
+
+//lookupRoute返回与pp形状相同的route，不存在则新建一个
+func (sm *ServeMux) lookupRoute(pp *pathPattern) *route {
+	if sm.m == nil {
+		sm.m = make(map[string]*route)
+	}
+	shape := pp.shape()
+	if !pp.hasParams() {
+		rt, ok := sm.m[shape]
+		if !ok {
+			rt = &route{pp: pp, entries: make(map[string]muxEntry)}
+			sm.m[shape] = rt
+		}
+		return rt
+	}
+	for _, rt := range sm.params {
+		if rt.pp.shape() == shape {
+			return rt
+		}
+	}
+	rt := &route{pp: pp, entries: make(map[string]muxEntry)}
+	i := sort.Search(len(sm.params), func(i int) bool {
+		return pp.moreSpecific(sm.params[i].pp)
+	})
+	sm.params = append(sm.params, nil)
+	copy(sm.params[i+1:], sm.params[i:])
+	sm.params[i] = rt
+	return rt
+}
+
+func (sm *ServeMux) Handle(pattern string, handler Handler) {
+	if handler == nil {
+		panic("httpd: nil handler for pattern " + pattern)
+	}
+	sm.HandleFunc(pattern, handler.ServeHTTP)
+}
+
+//Routes返回已注册的所有pattern，按字典序排列
+func (sm *ServeMux) Routes() []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	routes := make([]string, 0, len(sm.m)+len(sm.params))
+	for _, rt := range sm.m {
+		for _, e := range rt.entries {
+			routes = append(routes, e.pattern)
+		}
+	}
+	for _, rt := range sm.params {
+		for _, e := range rt.entries {
+			routes = append(routes, e.pattern)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+//DebugHandler以纯文本形式打印路由表，可以注册到如"/debug/routes"的路径下
+func (sm *ServeMux) DebugHandler() Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, route := range sm.Routes() {
+			io.WriteString(w, route+"\n")
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package httpd
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//AcceptSpec是Accept系列首部中的一项，如"text/html;level=1;q=0.8"
+type AcceptSpec struct {
+	Value  string
+	Q      float64
+	Params map[string]string //除q以外的参数
+}
+
+//ParseAccept解析Accept、Accept-Language、Accept-Charset和Accept-Encoding首部，
+//结果按q值从大到小排列，q值相同时保持原来的顺序。q值非法的项会被忽略
+func ParseAccept(values []string) []AcceptSpec {
+	var specs []AcceptSpec
+	for _, line := range values {
+		for _, item := range strings.Split(line, ",") {
+			parts := strings.Split(item, ";")
+			spec := AcceptSpec{Value: strings.ToLower(strings.TrimSpace(parts[0])), Q: 1}
+			if spec.Value == "" {
+				continue
+			}
+			valid := true
+			for _, param := range parts[1:] {
+				k, v := param, ""
+				if index := strings.IndexByte(param, '='); index != -1 {
+					k, v = param[:index], param[index+1:]
+				}
+				k, v = strings.ToLower(strings.TrimSpace(k)), strings.Trim(strings.TrimSpace(v), `"`)
+				if k != "q" {
+					if spec.Params == nil {
+						spec.Params = make(map[string]string)
+					}
+					spec.Params[k] = v
+					continue
+				}
+				q, err := strconv.ParseFloat(v, 64)
+				if err != nil || q < 0 || q > 1 {
+					valid = false
+					break
+				}
+				spec.Q = q
+			}
+			if valid {
+				specs = append(specs, spec)
+			}
+		}
+	}
+	sort.SliceStable(specs, func(i, j int) bool {
+		return specs[i].Q > specs[j].Q
+	})
+	return specs
+}
+
+//matchFunc返回spec能否匹配offer，以及匹配的精确程度，越大越精确
+type matchFunc func(spec, offer string) (specificity int, ok bool)
+
+//negotiate为每个offer找到最精确匹配的spec，以其q值作为offer的q值，返回q值最大的offer。
+//q值相同时取offers中靠前的；没有可接受的offer时返回""。
+//客户端没有发送对应首部时认为所有offer都可以接受，返回第一个
+func negotiate(values []string, offers []string, match matchFunc) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if len(values) == 0 {
+		return offers[0]
+	}
+	specs := ParseAccept(values)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, spec := range specs {
+			if s, ok := match(spec.Value, strings.ToLower(offer)); ok && s > specificity {
+				q, specificity = spec.Q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+func matchMediaType(spec, offer string) (int, bool) {
+	switch {
+	case spec == offer:
+		return 2, true
+	case spec == "*/*" || spec == "*":
+		return 0, true
+	case strings.HasSuffix(spec, "/*") && strings.HasPrefix(offer, spec[:len(spec)-1]):
+		return 1, true
+	}
+	return 0, false
+}
+
+//语言标签按前缀匹配(RFC 4647 section 3.3.1)，如"en"可以匹配"en-US"
+func matchLanguage(spec, offer string) (int, bool) {
+	switch {
+	case spec == offer:
+		return len(spec) + 1, true
+	case spec == "*":
+		return 0, true
+	case strings.HasPrefix(offer, spec+"-"):
+		return len(spec), true
+	}
+	return 0, false
+}
+
+func matchToken(spec, offer string) (int, bool) {
+	switch {
+	case spec == offer:
+		return 1, true
+	case spec == "*":
+		return 0, true
+	}
+	return 0, false
+}
+
+//Negotiate根据Accept首部从offers中选出最合适的媒体类型，如
+//r.Negotiate([]string{"application/json", "text/html"})。
+//返回""时handler应当回复406(StatusNotAcceptable)
+func (r *Request) Negotiate(offers []string) string {
+	return negotiate(r.Header["Accept"], offers, matchMediaType)
+}
+
+func (r *Request) NegotiateLanguage(offers []string) string {
+	return negotiate(r.Header["Accept-Language"], offers, matchLanguage)
+}
+
+func (r *Request) NegotiateCharset(offers []string) string {
+	return negotiate(r.Header["Accept-Charset"], offers, matchToken)
+}
+
+//identity编码在没有被显式排除(identity;q=0或*;q=0)时总是可以接受的
+func (r *Request) NegotiateEncoding(offers []string) string {
+	values := r.Header["Accept-Encoding"]
+	if len(values) == 0 {
+		return negotiate(nil, offers, matchToken)
+	}
+	excluded := false
+	for _, spec := range ParseAccept(values) {
+		if spec.Value == "identity" || spec.Value == "*" {
+			excluded = spec.Q == 0
+			if spec.Value == "identity" {
+				break
+			}
+		}
+	}
+	if !excluded {
+		values = append(values[:len(values):len(values)], "identity;q=0.001")
+	}
+	return negotiate(values, offers, matchToken)
+}
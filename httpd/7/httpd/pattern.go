@@ -0,0 +1,170 @@
+package httpd
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//路径参数的写法：
+//  {name}            匹配任意一段
+//  {name:int}        匹配不带符号和前导零的十进制非负整数，值为int
+//  {name:date}       匹配形如2006-01-02的日期，值为time.Time
+//  {name:[a-z0-9-]+} 其余的约束视为正则表达式，需要匹配整段
+//参数只能占据完整的一段，即两个'/'之间的全部内容
+type pathPattern struct {
+	segments []segment
+}
+
+const (
+	segStatic = iota
+	segInt
+	segDate
+	segRegexp
+	segAny
+)
+
+type segment struct {
+	kind    int
+	literal string //segStatic时为字面量
+	name    string //参数名
+	rule    string //约束的原始文本
+	re      *regexp.Regexp
+}
+
+func parsePathPattern(path string) (*pathPattern, error) {
+	parts := strings.Split(cleanPattern(path)[1:], "/")
+	pp := &pathPattern{segments: make([]segment, 0, len(parts))}
+	names := make(map[string]bool)
+	for _, part := range parts {
+		if len(part) < 2 || part[0] != '{' || part[len(part)-1] != '}' {
+			if strings.ContainsAny(part, "{}") {
+				return nil, errors.New("parameter must occupy a whole segment: " + part)
+			}
+			pp.segments = append(pp.segments, segment{kind: segStatic, literal: part})
+			continue
+		}
+		seg := segment{kind: segAny, name: part[1 : len(part)-1]}
+		if index := strings.IndexByte(seg.name, ':'); index != -1 {
+			seg.name, seg.rule = seg.name[:index], seg.name[index+1:]
+		}
+		if seg.name == "" {
+			return nil, errors.New("empty parameter name in " + part)
+		}
+		if names[seg.name] {
+			return nil, errors.New("duplicate parameter name " + seg.name)
+		}
+		names[seg.name] = true
+		switch seg.rule {
+		case "":
+		case "int":
+			seg.kind = segInt
+		case "date":
+			seg.kind = segDate
+		default:
+			re, err := regexp.Compile("^(?:" + seg.rule + ")$")
+			if err != nil {
+				return nil, err
+			}
+			seg.kind, seg.re = segRegexp, re
+		}
+		pp.segments = append(pp.segments, seg)
+	}
+	return pp, nil
+}
+
+func (pp *pathPattern) hasParams() bool {
+	for _, seg := range pp.segments {
+		if seg.kind != segStatic {
+			return true
+		}
+	}
+	return false
+}
+
+//shape忽略参数名，形状相同的两个pattern能匹配的路径完全一样，视为冲突
+func (pp *pathPattern) shape() string {
+	var b strings.Builder
+	for _, seg := range pp.segments {
+		b.WriteByte('/')
+		if seg.kind == segStatic {
+			b.WriteString(seg.literal)
+			continue
+		}
+		b.WriteString("{:" + seg.rule + "}")
+	}
+	return b.String()
+}
+
+//moreSpecific决定路由的优先级：从左往右逐段比较，
+//字面量优先于int/date，int/date优先于正则，正则优先于无约束参数
+func (pp *pathPattern) moreSpecific(other *pathPattern) bool {
+	for i := 0; i < len(pp.segments) && i < len(other.segments); i++ {
+		if pp.segments[i].kind != other.segments[i].kind {
+			return pp.segments[i].kind < other.segments[i].kind
+		}
+	}
+	return len(pp.segments) > len(other.segments)
+}
+
+//match在匹配成功时返回各个参数的原始字符串以及解析后的值
+func (pp *pathPattern) match(parts []string) (raw map[string]string, values map[string]interface{}, ok bool) {
+	if len(parts) != len(pp.segments) {
+		return nil, nil, false
+	}
+	raw = make(map[string]string)
+	values = make(map[string]interface{})
+	for i, seg := range pp.segments {
+		part := parts[i]
+		var value interface{} = part
+		switch seg.kind {
+		case segStatic:
+			if part != seg.literal {
+				return nil, nil, false
+			}
+			continue
+		case segAny:
+			if part == "" {
+				return nil, nil, false
+			}
+		case segInt:
+			//Atoi还接受"+5"、"05"，它们会让同一个资源有多个url
+			if !isCanonicalInt(part) {
+				return nil, nil, false
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, nil, false
+			}
+			value = n
+		case segDate:
+			t, err := time.Parse("2006-01-02", part)
+			if err != nil {
+				return nil, nil, false
+			}
+			value = t
+		case segRegexp:
+			if !seg.re.MatchString(part) {
+				return nil, nil, false
+			}
+		}
+		raw[seg.name] = part
+		values[seg.name] = value
+	}
+	return raw, values, true
+}
+
+//isCanonicalInt报告s是否只由数字组成，并且除了"0"本身外不以0开头
+func isCanonicalInt(s string) bool {
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
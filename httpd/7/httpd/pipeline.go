@@ -0,0 +1,153 @@
+package httpd
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//HTTP/1.1允许客户端不等响应就连续发送多个请求(pipelining)，但响应必须按请求的顺序返回。
+//开启Server.Pipelining后，如果客户端确实在上一个请求之后紧接着发来了后续请求，
+//安全方法且没有报文主体的请求会在各自的goroutine中并发处理。
+//排在最前面的响应直接写入连接，因此Flush照常生效；其余的响应先写入各自的缓存，
+//轮到它们时再由pipeline的写goroutine写回连接，之后同样直接写入连接。
+//遇到其他请求时，先等待之前所有的响应写完，再按原来的方式串行处理
+
+//同时在处理中的流水线请求的最大数量，超过时读请求的一方会阻塞
+const maxPipelineDepth = 16
+
+type pipeline struct {
+	c      *conn
+	queue  chan *pipelinedResponse
+	exited chan struct{}
+	//某个响应要求关闭连接或者写失败后置为1，之后的响应都不再写出
+	closed int32
+}
+
+type pipelinedResponse struct {
+	//mu保护buf和direct，handler的写与pipeline的写goroutine会同时访问它们
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	direct bool //轮到这个响应后置为true，此后的数据直接写入连接
+	resp   *response
+	done   chan struct{}
+	failed bool
+}
+
+//Write是response.w的底层，响应排在最前面之前写入缓存，之后直接写入连接
+func (pr *pipelinedResponse) Write(p []byte) (n int, err error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if !pr.direct {
+		return pr.buf.Write(p)
+	}
+	bufw := pr.resp.c.bufw
+	if n, err = bufw.Write(p); err != nil {
+		return
+	}
+	return n, bufw.Flush()
+}
+
+//startDirect将缓存中的数据写入连接，并让之后的数据直接写入连接
+func (pr *pipelinedResponse) startDirect() (err error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	bufw := pr.resp.c.bufw
+	if _, err = bufw.Write(pr.buf.Bytes()); err != nil {
+		return
+	}
+	pr.buf.Reset()
+	pr.direct = true
+	return bufw.Flush()
+}
+
+func newPipeline(c *conn) *pipeline {
+	p := &pipeline{
+		c:      c,
+		queue:  make(chan *pipelinedResponse, maxPipelineDepth-1),
+		exited: make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+//pipelinable判断请求能否与后续请求并发处理：只有安全方法、没有报文主体、
+//不需要100-continue并且不要求关闭连接的请求才可以。
+//此外客户端必须真的在流水线发送请求，即缓存中已经有后续请求的数据，
+//否则串行处理，这样Hijack和Flush都不受影响
+func (r *Request) pipelinable() bool {
+	if r.conn.pipe == nil && r.conn.bufr.Buffered() == 0 {
+		return false
+	}
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+	default:
+		return false
+	}
+	if _, ok := r.Body.(*eofReader); !ok {
+		return false
+	}
+	return r.Header.Get("Expect") == "" && !r.wantsClose()
+}
+
+func (p *pipeline) isClosed() bool {
+	return atomic.LoadInt32(&p.closed) == 1
+}
+
+//add开始并发处理req，返回false代表连接即将关闭，不应再读取后续请求
+func (p *pipeline) add(req *Request) bool {
+	if p.isClosed() {
+		return false
+	}
+	pr := &pipelinedResponse{done: make(chan struct{})}
+	pr.resp = p.c.setupResponse(req)
+	pr.resp.w = bufio.NewWriterSize(pr, 4<<10)
+	req.wrapBody(pr.resp)
+	p.queue <- pr
+	go pr.serve(p.c.svr.Handler, req)
+	return true
+}
+
+func (pr *pipelinedResponse) serve(handler Handler, req *Request) {
+	defer close(pr.done)
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("panic recoverred,err:%v\n", err)
+			pr.failed = true
+		}
+	}()
+	handler.ServeHTTP(pr.resp, req)
+	if err := req.finishRequest(pr.resp); err != nil {
+		pr.failed = true
+	}
+}
+
+//run按请求顺序依次让每个响应直接写入连接，并等待它处理完毕
+func (p *pipeline) run() {
+	defer close(p.exited)
+	for pr := range p.queue {
+		if p.isClosed() {
+			<-pr.done
+			continue
+		}
+		err := pr.startDirect()
+		<-pr.done
+		//handler出错时响应可能不完整，只能关闭连接
+		if err == nil && !pr.failed && !pr.resp.closeAfterReply {
+			continue
+		}
+		atomic.StoreInt32(&p.closed, 1)
+		//读请求的一方可能阻塞在Read上，让它立即返回
+		p.c.rwc.SetReadDeadline(time.Unix(1, 0))
+	}
+}
+
+//wait等待所有已经开始处理的请求写回，返回false代表连接需要关闭
+func (p *pipeline) wait() bool {
+	close(p.queue)
+	<-p.exited
+	return !p.isClosed()
+}
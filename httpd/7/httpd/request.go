@@ -7,36 +7,62 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Request struct {
 	Method     string
 	URL        *url.URL
-	Proto      string
+	Proto      string //如"HTTP/1.1"
+	ProtoMajor int
+	ProtoMinor int
 	Header     Header
 	Body       io.Reader
 	RemoteAddr string
 	RequestURI string //字符串形式的url
+	//absolute-form的请求中取自url，否则取自Host首部
+	Host string
+	//报文主体的长度，-1代表使用chunk编码、长度未知
+	ContentLength int64
+	//chunk编码的报文主体之后的trailer，只有在Body读到io.EOF之后才是完整的。
+	//Trailer首部中声明的key会预先以nil值放入
+	Trailer Header
 
 	//私有字段
 	conn          *conn
-	cookies       map[string]string
-	queryString   map[string]string
-	postForm      map[string]string
+	cookies       []*Cookie
+	parsedCookies bool
+	//请求带有Expect: 100-continue并且有报文主体时不为nil
+	expect        *expectContinueReader
+	queryString   Values
+	postForm      Values
+	form          Values
 	multipartForm *MultipartForm
+	//路由中的参数，由ServeMux在匹配时设置
+	params      map[string]string
+	paramValues map[string]interface{}
 
 	contentType    string
 	boundary       string
 	haveParsedForm bool
 	parseFormErr   error
+
+	//解压之前的Body，handler结束后从这里读完剩余的报文主体
+	wireBody        io.Reader
+	contentEncoding string
+	//报文边界存在歧义时为true，回复后必须关闭连接
+	ambiguousFraming bool
 }
 
 func readRequest(c *conn) (r *Request, err error) {
 	r = new(Request)
 	r.conn = c
+	//每个请求的首部都重新计算限制
+	c.lr.N = maxHeaderBytes
 	r.RemoteAddr = c.rwc.RemoteAddr().String()
 	//读出第一行,如：Get /index HTTP/1.1
 	line, err := readLine(c.bufr)
@@ -45,62 +71,201 @@ func readRequest(c *conn) (r *Request, err error) {
 	}
 	_, err = fmt.Sscanf(string(line), "%s%s%s", &r.Method, &r.RequestURI, &r.Proto)
 	if err != nil {
-		return
+		return nil, badRequest("malformed request line")
 	}
-	r.URL, err = url.ParseRequestURI(r.RequestURI)
-	if err != nil {
-		return
+	var ok bool
+	if r.ProtoMajor, r.ProtoMinor, ok = ParseHTTPVersion(r.Proto); !ok {
+		return nil, badRequest("malformed HTTP version")
+	}
+	if r.ProtoMajor != 1 {
+		return nil, &statusError{code: StatusHTTPVersionNotSupported, text: "unsupported HTTP version"}
+	}
+	if err = r.parseRequestURI(); err != nil {
+		return nil, err
 	}
 	r.parseQuery()
 	//读header
-	r.Header, err = readHeader(c.bufr)
+	r.Header, err = readHeader(c.bufr, c.svr.StrictParsing)
 	if err != nil {
 		return
 	}
-	const noLimit = (1 << 63) - 1
+	if err = r.setupHost(); err != nil {
+		return nil, err
+	}
 	r.conn.lr.N = noLimit
 	//设置body
-	r.setupBody()
+	if err = r.checkExpect(); err != nil {
+		return nil, err
+	}
+	if err = r.setupBody(); err != nil {
+		return nil, err
+	}
+	if err = r.checkContentEncoding(); err != nil {
+		return nil, err
+	}
 	r.parseContentType()
 	return r, nil
 }
 
+//request-target有四种形式(RFC 7230 section 5.3)：
+//  origin-form    /index?a=1
+//  absolute-form  http://www.example.com/index?a=1，一般是发给代理的请求
+//  authority-form www.example.com:443，只用于CONNECT
+//  asterisk-form  *，只用于OPTIONS
+//absolute-form的path为空时补上"/"，这样路由总能看到一个合法的path
+func (r *Request) parseRequestURI() (err error) {
+	if r.Method == "CONNECT" && !strings.HasPrefix(r.RequestURI, "/") {
+		if !validHost(r.RequestURI) {
+			return badRequest("malformed request uri")
+		}
+		r.URL = &url.URL{Host: r.RequestURI}
+		return nil
+	}
+	r.URL, err = url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return badRequest("malformed request uri")
+	}
+	if r.URL.IsAbs() {
+		if r.URL.Host == "" || !validHost(r.URL.Host) {
+			return badRequest("malformed request uri")
+		}
+		if r.URL.Path == "" {
+			r.URL.Path = "/"
+		}
+	}
+	return nil
+}
+
+//absolute-form的请求忽略Host首部(RFC 7230 section 5.4)，
+//其余情况下HTTP/1.1的请求必须有且只有一个Host首部
+func (r *Request) setupHost() error {
+	hosts, ok := r.Header["Host"]
+	if len(hosts) > 1 {
+		return badRequest("multiple Host headers")
+	}
+	if !ok {
+		if r.ProtoAtLeast(1, 1) {
+			return badRequest("missing Host header")
+		}
+	} else {
+		if !validHost(hosts[0]) {
+			return badRequest("malformed Host header")
+		}
+		r.Host = hosts[0]
+	}
+	//absolute-form的请求目标中的主机优先于Host首部，但HTTP/1.1请求仍然必须带有Host首部
+	if r.URL.Host != "" {
+		r.Host = r.URL.Host
+	}
+	return nil
+}
+
+//validHost只做字符层面的检查，Host首部允许为空
+func validHost(host string) bool {
+	for i := 0; i < len(host); i++ {
+		c := host[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-._~%!$&'()*+,;=:[]@", c) != -1:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+//ParseHTTPVersion解析形如"HTTP/1.1"的版本号，主次版本号都只能是一位数字(RFC 7230 section 2.6)
+func ParseHTTPVersion(vers string) (major, minor int, ok bool) {
+	if len(vers) != len("HTTP/1.1") || !strings.HasPrefix(vers, "HTTP/") || vers[6] != '.' {
+		return 0, 0, false
+	}
+	if vers[5] < '0' || vers[5] > '9' || vers[7] < '0' || vers[7] > '9' {
+		return 0, 0, false
+	}
+	return int(vers[5] - '0'), int(vers[7] - '0'), true
+}
+
+func (r *Request) ProtoAtLeast(major, minor int) bool {
+	return r.ProtoMajor > major || r.ProtoMajor == major && r.ProtoMinor >= minor
+}
+
+//wantsClose按照RFC 7230 section 6.3判断客户端是否希望在本次请求后关闭连接：
+//HTTP/1.1默认保持连接，除非Connection中带有close；
+//HTTP/1.0默认关闭连接，除非Connection中带有keep-alive。
+//报文边界存在歧义的请求总是关闭连接
+func (r *Request) wantsClose() bool {
+	if r.ambiguousFraming {
+		return true
+	}
+	conn := r.Header["Connection"]
+	if hasToken(conn, "close") {
+		return true
+	}
+	if r.ProtoAtLeast(1, 1) {
+		return false
+	}
+	return !hasToken(conn, "keep-alive")
+}
+
+//statusError代表解析请求时出现的、需要以code回复客户端的错误
+type statusError struct {
+	code int
+	text string
+}
+
+func (e *statusError) Error() string {
+	return strconv.Itoa(e.code) + " " + e.text
+}
+
+func badRequest(text string) error {
+	return &statusError{code: StatusBadRequest, text: text}
+}
+
 //读取一整行
 func readLine(bufr *bufio.Reader) ([]byte, error) {
+	return readLineLimit(bufr, 0)
+}
+
+var errLineTooLong = errors.New("line too long")
+
+//readLineLimit与readLine相同，但一行超过max字节时返回errLineTooLong，max为0代表不限制。
+//不受lr限制的地方(如报文主体中的块大小行)需要用它防止一行无限增长
+func readLineLimit(bufr *bufio.Reader, max int) ([]byte, error) {
 	p, isPrefix, err := bufr.ReadLine()
 	if err != nil {
 		return p, err
 	}
+	//p引用的是bufr内部的缓存，下一次ReadLine会覆盖它，所以需要先拷贝一份
+	if isPrefix {
+		p = append([]byte(nil), p...)
+	}
 	var l []byte
 	for isPrefix {
+		if max > 0 && len(p) > max {
+			return nil, errLineTooLong
+		}
 		l, isPrefix, err = bufr.ReadLine()
 		if err != nil {
 			break
 		}
 		p = append(p, l...)
 	}
+	if max > 0 && len(p) > max {
+		return nil, errLineTooLong
+	}
 	return p, err
 }
 
+//无法解码的键值对直接忽略
 func (r *Request) parseQuery() {
-	r.queryString = parseQuery(r.URL.RawQuery)
-}
-
-func parseQuery(RawQuery string) map[string]string {
-	parts := strings.Split(RawQuery, "&")
-	queries := make(map[string]string, len(parts))
-	for _, part := range parts {
-		index := strings.IndexByte(part, '=')
-		if index == -1 || index == len(part)-1 {
-			continue
-		}
-		queries[strings.TrimSpace(part[:index])] = strings.TrimSpace(part[index+1:])
-	}
-	return queries
+	r.queryString, _ = ParseQuery(r.URL.RawQuery)
 }
 
-func readHeader(bufr *bufio.Reader) (Header, error) {
+//strict为true时，以空白开头的折叠行(obs-fold)、冒号前带空白以及含非法字符的key
+//都会被拒绝；否则折叠行会拼接到上一个value，key两端的空白会被去掉
+func readHeader(bufr *bufio.Reader, strict bool) (Header, error) {
 	header := make(Header)
+	var lastKey string
 	for {
 		line, err := readLine(bufr)
 		if err != nil {
@@ -110,67 +275,112 @@ func readHeader(bufr *bufio.Reader) (Header, error) {
 		if len(line) == 0 {
 			break
 		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if strict || lastKey == "" {
+				return nil, badRequest("obsolete line folding in header")
+			}
+			vs := header[lastKey]
+			vs[len(vs)-1] += " " + strings.TrimSpace(string(line))
+			continue
+		}
 		i := bytes.IndexByte(line, ':')
 		if i == -1 {
-			return nil, errors.New("unsupported protocol")
+			return nil, badRequest("malformed header line")
+		}
+		key := string(line[:i])
+		if !strict {
+			key = strings.TrimSpace(key)
+		}
+		if !validHeaderKey(key) {
+			return nil, badRequest("invalid header key " + strconv.Quote(key))
 		}
 		if i == len(line)-1 {
+			lastKey = ""
 			continue
 		}
-		k, v := string(line[:i]), strings.TrimSpace(string(line[i+1:]))
+		k, v := CanonicalHeaderKey(key), strings.TrimSpace(string(line[i+1:]))
 		header[k] = append(header[k], v)
+		lastKey = k
 	}
 	return header, nil
 }
 
-func (r *Request) parseCookies() {
-	if r.cookies != nil {
-		return
+func validHeaderKey(key string) bool {
+	if key == "" {
+		return false
 	}
-	r.cookies = make(map[string]string)
-	rawCookies, ok := r.Header["Cookie"]
-	if !ok {
-		return
-	}
-	for _, line := range rawCookies {
-		kvs := strings.Split(strings.TrimSpace(line), ";")
-		if len(kvs) == 1 && kvs[0] == "" {
-			continue
-		}
-		for i := 0; i < len(kvs); i++ {
-			index := strings.IndexByte(kvs[i], '=')
-			if index == -1 {
-				continue
-			}
-			r.cookies[strings.TrimSpace(kvs[i][:index])] = strings.TrimSpace(kvs[i][index+1:])
+	for i := 0; i < len(key); i++ {
+		if !isTokenChar(key[i]) {
+			return false
 		}
 	}
-	return
+	return true
 }
 
-func (r *Request) Cookie(name string) string {
-	if r.cookies == nil {
-		r.parseCookies()
-	}
-	return r.cookies[name]
+func (r *Request) Query(name string) string {
+	return r.queryString.Get(name)
 }
 
-func (r *Request) Query(name string) string {
-	return r.queryString[name]
+func (r *Request) QueryValues() Values {
+	return r.queryString
+}
+
+//Param返回路由参数的原始字符串
+func (r *Request) Param(name string) string {
+	return r.params[name]
+}
+
+//ParamValue返回路由参数按约束解析后的值：
+//{name:int}为int，{name:date}为time.Time，其余为string
+func (r *Request) ParamValue(name string) interface{} {
+	return r.paramValues[name]
+}
+
+func (r *Request) ParamInt(name string) int {
+	n, _ := r.paramValues[name].(int)
+	return n
+}
+
+func (r *Request) ParamTime(name string) time.Time {
+	t, _ := r.paramValues[name].(time.Time)
+	return t
 }
 
 type eofReader struct{}
 
 func (er *eofReader) Read([]byte) (n int, err error) { return 0, io.EOF }
 
+//wireBodyReader包装直接从连接上读取的报文主体。读取出错(如非法的chunk编码)时，
+//报文的边界已经无法确定，后面的数据可能是走私的请求，回复后必须关闭连接
+type wireBodyReader struct {
+	r    io.Reader
+	resp *response
+}
+
+func (wr *wireBodyReader) Read(p []byte) (n int, err error) {
+	n, err = wr.r.Read(p)
+	if err != nil && err != io.EOF {
+		wr.resp.closeAfterReply = true
+		if wr.resp.bodyErr == nil {
+			wr.resp.bodyErr = err
+		}
+	}
+	return
+}
+
+//客户端发送Expect: 100-continue后会等待服务器的答复再发送报文主体。
+//我们在handler第一次读取Body时才回复100 Continue，这样handler可以不读Body，
+//直接以401、413等状态码拒绝这次上传
 type expectContinueReader struct {
 	wroteContinue bool
-	r             io.Reader
-	w             *bufio.Writer
+	//handler已经写出了最终响应的首部，此时不能再发送100 Continue
+	finalSent bool
+	r         io.Reader
+	w         *bufio.Writer
 }
 
 func (er *expectContinueReader) Read(p []byte) (n int, err error) {
-	if !er.wroteContinue {
+	if !er.wroteContinue && !er.finalSent {
 		er.w.WriteString("HTTP/1.1 100 Continue\r\n\r\n")
 		er.w.Flush()
 		er.wroteContinue = true
@@ -178,39 +388,121 @@ func (er *expectContinueReader) Read(p []byte) (n int, err error) {
 	return er.r.Read(p)
 }
 
+//checkExpect在handler执行前检查Expect首部，我们只支持100-continue，
+//其余的期望一律回复417。HTTP/1.0的请求忽略Expect(RFC 7231 section 5.1.1)
+func (r *Request) checkExpect() error {
+	expect, ok := r.Header["Expect"]
+	if !ok || !r.ProtoAtLeast(1, 1) {
+		return nil
+	}
+	if len(expect) != 1 || !strings.EqualFold(expect[0], "100-continue") {
+		return &statusError{code: StatusExpectationFailed, text: "unsupported expectation"}
+	}
+	return nil
+}
+
 func (r *Request) fixExpectContinueReader() {
-	if r.Header.Get("Expect") != "100-continue" {
+	if !r.ProtoAtLeast(1, 1) || !strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
 		return
 	}
-	r.Body = &expectContinueReader{
+	r.expect = &expectContinueReader{
 		r: r.Body,
 		w: r.conn.bufw,
 	}
+	r.Body = r.expect
 }
 
+//Transfer-Encoding可能是多个编码的列表，chunked必须是最后一个
 func (r *Request) chunked() bool {
-	te := r.Header.Get("Transfer-Encoding")
-	return te == "chunked"
+	te := r.Header.Values("Transfer-Encoding")
+	if len(te) == 0 {
+		return false
+	}
+	codings := strings.Split(te[len(te)-1], ",")
+	return strings.EqualFold(strings.TrimSpace(codings[len(codings)-1]), "chunked")
 }
 
-func (r *Request) setupBody() {
-	if r.Method != "POST" && r.Method != "PUT" {
-		r.Body = &eofReader{} //POST和PUT以外的方法不允许设置报文主体
-	} else if r.chunked() {
-		r.Body = &chunkReader{bufr: r.conn.bufr}
-		r.fixExpectContinueReader()
-	} else if cl := r.Header.Get("Content-Length"); cl != "" {
-		//如果设置了Content-Length
-		contentLength, err := strconv.ParseInt(cl, 10, 64)
-		if err != nil {
-			r.Body = &eofReader{}
-			return
+//按照RFC 7230 section 3.3.3，报文主体是否存在只取决于Transfer-Encoding
+//和Content-Length，与请求方法无关。两者都没有设置时没有报文主体
+func (r *Request) setupBody() error {
+	r.Body = &eofReader{}
+	_, hasCL := r.Header["Content-Length"]
+	if _, ok := r.Header["Transfer-Encoding"]; ok {
+		//同时出现时，前置代理和我们可能对报文边界有不同的理解，这是请求走私的常见手法。
+		//RFC 7230要求以Transfer-Encoding为准，严格模式下直接拒绝
+		if hasCL {
+			if r.conn.svr.StrictParsing {
+				return badRequest("both Transfer-Encoding and Content-Length are set")
+			}
+			//宽松模式下仍以Transfer-Encoding解析，但回复后关闭连接，
+			//不让可能被走私的数据被当作下一个请求(RFC 9112 section 6.3)
+			r.Header.Del("Content-Length")
+			r.ambiguousFraming = true
 		}
+		//chunked不是最后一个编码时无法确定报文的边界
+		if !r.chunked() {
+			return &statusError{code: StatusNotImplemented, text: "unsupported transfer encoding"}
+		}
+		r.ContentLength = -1
+		r.Trailer = make(Header)
+		for _, line := range r.Header["Trailer"] {
+			for _, key := range strings.Split(line, ",") {
+				if key = CanonicalHeaderKey(strings.TrimSpace(key)); key != "" && !forbiddenTrailer[key] {
+					r.Trailer[key] = nil
+				}
+			}
+		}
+		r.Body = &chunkReader{
+			bufr:    r.conn.bufr,
+			lr:      r.conn.lr,
+			trailer: r.Trailer,
+			strict:  r.conn.svr.StrictParsing,
+		}
+		r.fixExpectContinueReader()
+		return nil
+	}
+	if !hasCL {
+		return nil
+	}
+	//Content-Length非法时无法确定下一个请求从哪里开始，只能拒绝
+	contentLength, err := r.parseContentLength()
+	if err != nil {
+		return err
+	}
+	r.ContentLength = contentLength
+	if contentLength > 0 {
 		r.Body = io.LimitReader(r.conn.bufr, contentLength)
 		r.fixExpectContinueReader()
-	} else {
-		r.Body = &eofReader{}
 	}
+	return nil
+}
+
+//多个Content-Length(包括"3, 3"这样的列表)的值不一致时一律拒绝，
+//值一致时只在严格模式下拒绝
+func (r *Request) parseContentLength() (int64, error) {
+	var values []string
+	for _, line := range r.Header["Content-Length"] {
+		for _, v := range strings.Split(line, ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+	}
+	if len(values) > 1 && r.conn.svr.StrictParsing {
+		return 0, badRequest("multiple Content-Length headers")
+	}
+	for _, v := range values[1:] {
+		if v != values[0] {
+			return 0, badRequest("conflicting Content-Length headers")
+		}
+	}
+	//ParseInt允许的正负号在这里都是非法的
+	if values[0] == "" || values[0][0] == '+' || values[0][0] == '-' {
+		return 0, badRequest("invalid content length")
+	}
+	contentLength, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil {
+		return 0, badRequest("invalid content length")
+	}
+	return contentLength, nil
 }
 
 func (r *Request) finishRequest(resp *response) (err error) {
@@ -218,50 +510,54 @@ func (r *Request) finishRequest(resp *response) (err error) {
 		r.multipartForm.RemoveAll()
 	}
 	resp.handlerDone = true
+	//handler读Body时遇到了报文格式错误，却没有写出任何响应，按错误对应的状态码回复
+	if se, ok := resp.bodyErr.(*statusError); ok && !resp.wroteHeader && !resp.cw.wrote && resp.bufw.Buffered() == 0 {
+		Error(resp, r, se.code)
+	}
 	if err = resp.bufw.Flush(); err != nil {
 		return
 	}
-	//将最后的0\r\n\r\n传输
-	if resp.chunking {
-		_, err = resp.c.bufw.WriteString("0\r\n\r\n")
-		if err != nil {
-			return
-		}
-	}
 	//如果用户的handler中未Write任何数据，我们手动触发(*chunkWriter).writeHeader
-	if !resp.cw.wrote {
-		resp.header.Set("Content-Length", "0")
-		if err = resp.cw.writeHeader(); err != nil {
+	//此时handler已结束，报文主体为空，finalizeHeader会设置Content-Length: 0
+	if err = resp.cw.writeHeaderOnce(nil); err != nil {
+		return
+	}
+	//传输最后长度为0的块以及trailer
+	if resp.chunking {
+		if err = resp.writeTrailers(); err != nil {
 			return
 		}
 	}
 
 	//将缓存中的剩余的数据发送到rwc中
-	if err = r.conn.bufw.Flush(); err != nil {
+	if err = resp.w.Flush(); err != nil {
 		return
 	}
-	_, err = io.Copy(ioutil.Discard, r.Body)
+	//连接即将关闭，没必要再读完剩余的报文主体
+	if resp.closeAfterReply {
+		return nil
+	}
+	body := r.wireBody
+	if body == nil {
+		body = r.Body
+	}
+	_, err = io.Copy(ioutil.Discard, body)
 	return err
 }
 
 func (r *Request) parseContentType() {
 	ct := r.Header.Get("Content-Type")
-	//Content-Type: multipart/form-data; boundary=------974767299852498929531610575
-	//Content-Type: application/x-www-form-urlencoded
-	index := strings.IndexByte(ct, ';')
-	if index == -1 {
-		r.contentType = ct
-		return
-	}
-	if index == len(ct)-1 {
+	if ct == "" {
 		return
 	}
-	ss := strings.Split(ct[index+1:], "=")
-	if len(ss) < 2 || strings.TrimSpace(ss[0]) != "boundary" {
+	//Content-Type: multipart/form-data; boundary=------974767299852498929531610575
+	//Content-Type: application/x-www-form-urlencoded; charset=UTF-8
+	//媒体类型不区分大小写，参数可能不止boundary一个，ParseMediaType会统一转为小写
+	mediatype, params, err := mime.ParseMediaType(ct)
+	if err != nil && mediatype == "" {
 		return
 	}
-	r.contentType, r.boundary = ct[:index], strings.Trim(ss[1], `"`)
-	return
+	r.contentType, r.boundary = mediatype, params["boundary"]
 }
 
 func (r *Request) MultipartReader() (*MultipartReader, error) {
@@ -278,7 +574,32 @@ func (r *Request) PostForm(name string) string {
 	if r.parseFormErr != nil || r.postForm == nil {
 		return ""
 	}
-	return r.postForm[name]
+	return r.postForm.Get(name)
+}
+
+//Form合并了表单和query string中的值，同一个key下表单的值排在前面。
+//表单解析失败时只包含query string中的值
+func (r *Request) Form() Values {
+	if r.form != nil {
+		return r.form
+	}
+	if !r.haveParsedForm {
+		r.parseFormErr = r.parseForm()
+	}
+	r.form = make(Values)
+	if r.parseFormErr == nil {
+		for k, vs := range r.postForm {
+			r.form[k] = append(r.form[k], vs...)
+		}
+	}
+	for k, vs := range r.queryString {
+		r.form[k] = append(r.form[k], vs...)
+	}
+	return r.form
+}
+
+func (r *Request) FormValue(name string) string {
+	return r.Form().Get(name)
 }
 
 func (r *Request) MultipartForm() (*MultipartForm, error) {
@@ -292,10 +613,10 @@ func (r *Request) MultipartForm() (*MultipartForm, error) {
 }
 
 func (r *Request) parseForm() error {
-	if r.Method != "POST" && r.Method != "PUT" {
+	r.haveParsedForm = true
+	if _, ok := r.Body.(*eofReader); ok {
 		return errors.New("missing form body")
 	}
-	r.haveParsedForm = true
 	switch r.contentType {
 	case "application/x-www-form-urlencoded":
 		return r.parsePostForm()
@@ -306,21 +627,15 @@ func (r *Request) parseForm() error {
 	}
 }
 
-func (r *Request) parsePostForm() error {
-	data, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		return err
-	}
-	r.postForm = parseQuery(string(data))
-	return nil
-}
-
 func (r *Request) parseMultipartForm() (err error) {
 	mr, err := r.MultipartReader()
 	if err != nil {
 		return
 	}
 	r.multipartForm, err = mr.ReadForm()
+	if err != nil {
+		return
+	}
 	r.postForm = r.multipartForm.Value
 	return
 }
@@ -0,0 +1,69 @@
+package httpd
+
+import (
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+//serveRaw在一个内存连接上处理客户端发来的原始报文，返回服务器写回的全部数据
+func serveRaw(t *testing.T, svr *Server, raw string) string {
+	client, server := net.Pipe()
+	defer client.Close()
+	go newConn(server, svr).serve()
+	go func() {
+		client.Write([]byte(raw))
+	}()
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	b, err := ioutil.ReadAll(client)
+	if err != nil {
+		t.Fatalf("connection was not closed: %v, read %q", err, b)
+	}
+	return string(b)
+}
+
+//非法的chunk编码出现后，后面的数据不能被当作下一个请求处理
+func TestMalformedChunkedBodyClosesConnection(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		var paths []string
+		svr := &Server{
+			StrictParsing: strict,
+			Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+				paths = append(paths, r.URL.Path)
+				ioutil.ReadAll(r.Body)
+				w.Write([]byte("ok"))
+			}),
+		}
+		out := serveRaw(t, svr, "POST /read HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n"+
+			"zz\r\n0\r\n\r\n"+
+			"GET /admin HTTP/1.1\r\nHost: x\r\n\r\n")
+		if len(paths) != 1 || paths[0] != "/read" {
+			t.Errorf("strict=%v: served %v, want only /read", strict, paths)
+		}
+		if strings.Count(out, "HTTP/1.1 ") != 1 || !strings.Contains(out, "Connection: close\r\n") {
+			t.Errorf("strict=%v: got %q, want a single response with Connection: close", strict, out)
+		}
+	}
+}
+
+//块大小所在行和trailer不受lr限制，必须有自己的长度上限
+func TestChunkedBodyLimits(t *testing.T) {
+	svr := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		ioutil.ReadAll(r.Body)
+	})}
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"1;" + strings.Repeat("x", 1<<20) + "\r\na\r\n0\r\n\r\n", "HTTP/1.1 400 "},
+		{"0\r\n" + strings.Repeat("X-A: b\r\n", maxHeaderBytes/4) + "\r\n", "HTTP/1.1 431 "},
+	}
+	for _, tt := range tests {
+		out := serveRaw(t, svr, "POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n"+tt.body)
+		if !strings.HasPrefix(out, tt.want) {
+			t.Errorf("got %.40q, want prefix %q", out, tt.want)
+		}
+	}
+}
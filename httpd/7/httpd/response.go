@@ -2,7 +2,8 @@ package httpd
 
 import (
 	"bufio"
-	"fmt"
+	"errors"
+	"net"
 )
 
 type response struct {
@@ -23,6 +24,9 @@ type response struct {
 	//it's a wrapper of chunkWriter
 	bufw *bufio.Writer
 	cw   *chunkWriter
+	//响应报文最终写入的地方，一般是conn.bufw，
+	//并发处理流水线请求时是一块独立的缓存，见pipeline
+	w *bufio.Writer
 
 	req *Request
 
@@ -30,6 +34,10 @@ type response struct {
 	closeAfterReply bool
 
 	chunking bool
+	//handler在Trailer首部中声明的trailer，在写出响应首部时确定
+	trailers []string
+	//从连接上读取报文主体时遇到的第一个错误
+	bodyErr error
 }
 
 type ResponseWriter interface {
@@ -41,6 +49,7 @@ type ResponseWriter interface {
 func setupResponse(c *conn, req *Request) *response {
 	resp := &response{
 		c:          c,
+		w:          c.bufw,
 		header:     make(Header),
 		statusCode: 200,
 		req:        req,
@@ -48,20 +57,34 @@ func setupResponse(c *conn, req *Request) *response {
 	cw := &chunkWriter{resp: resp}
 	resp.cw = cw
 	resp.bufw = bufio.NewWriterSize(cw, 4096)
-	var (
-		protoMinor int
-		protoMajor int
-	)
-	fmt.Sscanf(req.Proto, "HTTP/%d.%d", &protoMinor, &protoMajor)
-	if protoMajor < 1 || protoMinor == 1 && protoMajor == 0 || req.Header.Get("Connection") == "close" {
-		resp.closeAfterReply = true
-	}
+	resp.closeAfterReply = req.wantsClose()
 	return resp
 }
 
 //写入流的顺序：response => (*response).bufw => chunkWriter
-// =>  (*response).(*conn).bufw => net.Conn
+// =>  (*response).w(一般是(*conn).bufw) => net.Conn
+var ErrBodyNotAllowed = errors.New("http: request method or response status code does not allow body")
+
+//bodyAllowedForStatus报告状态码为code的响应能否携带报文主体，1xx、204和304不能
+func bodyAllowedForStatus(code int) bool {
+	switch {
+	case code >= 100 && code <= 199:
+		return false
+	case code == StatusNoContent, code == StatusNotModified:
+		return false
+	}
+	return true
+}
+
+//Write写入报文主体。状态码不允许携带报文主体时返回ErrBodyNotAllowed；
+//HEAD请求的响应会丢弃写入的数据，但仍据此计算Content-Length
 func (w *response) Write(p []byte) (int, error) {
+	if w.c.hijacked {
+		return 0, ErrHijacked
+	}
+	if !bodyAllowedForStatus(w.statusCode) {
+		return 0, ErrBodyNotAllowed
+	}
 	n, err := w.bufw.Write(p)
 	if err != nil {
 		w.closeAfterReply = true
@@ -69,6 +92,70 @@ func (w *response) Write(p []byte) (int, error) {
 	return n, err
 }
 
+//Flusher由ResponseWriter实现，handler可以通过类型断言取得：
+//  if f, ok := w.(httpd.Flusher); ok {
+//      f.Flush()
+//  }
+type Flusher interface {
+	//Flush将已经写入的数据立即发送给客户端。首部尚未写出时会先写出首部，
+	//此时报文长度未知，HTTP/1.1使用chunk编码，HTTP/1.0则在响应结束后关闭连接。
+	//并发处理的流水线请求的响应要等到之前的响应都写回后才会发送
+	Flush()
+}
+
+func (w *response) Flush() {
+	//连接已经交给handler，不能再往上面写任何东西
+	if w.c.hijacked {
+		return
+	}
+	if w.bufw.Buffered() == 0 {
+		if err := w.cw.writeHeaderOnce(nil); err != nil {
+			w.closeAfterReply = true
+			return
+		}
+	}
+	//response.bufw => chunkWriter => response.w => net.Conn
+	if err := w.bufw.Flush(); err != nil {
+		w.closeAfterReply = true
+		return
+	}
+	if err := w.w.Flush(); err != nil {
+		w.closeAfterReply = true
+	}
+}
+
+var ErrHijacked = errors.New("http: connection has been hijacked")
+
+//Hijacker由ResponseWriter实现，handler可以借此接管底层的tcp连接，
+//用来实现协议升级(如WebSocket)或者隧道
+type Hijacker interface {
+	//Hijack返回底层连接以及连接上的读写缓存，bufio.Reader中可能已经缓存了客户端发来的数据。
+	//调用成功后服务器不再读写和关闭这个连接，也不会再写出响应，
+	//handler负责之后的一切，包括关闭连接。并发处理的流水线请求不能接管连接
+	Hijack() (net.Conn, *bufio.ReadWriter, error)
+}
+
+func (w *response) Hijack() (rwc net.Conn, buf *bufio.ReadWriter, err error) {
+	c := w.c
+	if c.hijacked {
+		return nil, nil, ErrHijacked
+	}
+	if w.w != c.bufw {
+		return nil, nil, errors.New("http: cannot hijack a pipelined request")
+	}
+	//已经开始发送的响应要完整地发出去，尚未发送的响应首部则直接丢弃
+	if w.cw.wrote {
+		if err = w.bufw.Flush(); err != nil {
+			return
+		}
+	}
+	if err = c.bufw.Flush(); err != nil {
+		return
+	}
+	c.hijacked = true
+	return c.rwc, bufio.NewReadWriter(c.bufr, c.bufw), nil
+}
+
 func (w *response) Header() Header {
 	return w.header
 }
@@ -9,6 +9,28 @@ type Handler interface {
 type Server struct {
 	Addr    string
 	Handler Handler
+
+	//ErrorPages为nil时，错误响应使用纯文本的默认页面
+	ErrorPages *ErrorPages
+
+	//严格模式下，同时带有Transfer-Encoding和Content-Length、多个Content-Length、
+	//首部冒号前有空白以及首部折叠行的请求都会以400拒绝。部署在代理之后时应当开启
+	StrictParsing bool
+
+	//报文主体的最大长度，0代表不限制。超出时Body返回ErrBodyTooLarge，
+	//由handler决定如何回复(一般是413)，回复后连接会被关闭
+	MaxRequestBodyBytes int64
+	//gzip或deflate压缩的报文主体解压后的最大长度，0代表默认的32MB，小于0代表不限制
+	MaxDecompressedBodyBytes int64
+	//application/x-www-form-urlencoded表单的解析选项
+	FormOptions FormOptions
+
+	//开启后，同一连接上流水线发送的GET、HEAD、OPTIONS请求会被并发处理，
+	//响应仍按请求顺序返回。handler需要是并发安全的
+	Pipelining bool
+
+	//响应中Server首部的值，为空时不发送Server首部
+	ServerHeader string
 }
 
 func (s *Server) ListenAndServe() error {
@@ -28,48 +50,14 @@ func (s *Server) ListenAndServe() error {
 
 type HandlerFunc func(ResponseWriter, *Request)
 
-type ServeMux struct {
-	m map[string]HandlerFunc
-}
-
-func NewServeMux() *ServeMux {
-	return &ServeMux{
-		m: make(map[string]HandlerFunc),
-	}
-}
-
-func (sm *ServeMux) ServeHTTP(w ResponseWriter, r *Request) {
-	handler, ok := sm.m[r.URL.Path]
-	if !ok {
-		if len(r.URL.Path) > 1 && r.URL.Path[len(r.URL.Path)-1] == '/' {
-			handler, ok = sm.m[r.URL.Path[:len(r.URL.Path)-1]]
-		}
-		if !ok {
-			w.WriteHeader(StatusNotFound)
-			return
-		}
-	}
-	handler(w, r)
+func (f HandlerFunc) ServeHTTP(w ResponseWriter, r *Request) {
+	f(w, r)
 }
 
 var defaultServeMux ServeMux
 
 var DefaultServeMux = &defaultServeMux
 
-func (sm *ServeMux) HandleFunc(pattern string, cb HandlerFunc) {
-	if sm.m == nil {
-		sm.m = make(map[string]HandlerFunc)
-	}
-	sm.m[pattern] = cb
-}
-
-func (sm *ServeMux) Handle(pattern string, handler Handler) {
-	if sm.m == nil {
-		sm.m = make(map[string]HandlerFunc)
-	}
-	sm.m[pattern] = handler.ServeHTTP
-}
-
 func HandleFunc(pattern string, cb HandlerFunc) {
 	DefaultServeMux.HandleFunc(pattern, cb)
 }
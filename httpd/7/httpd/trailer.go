@@ -0,0 +1,83 @@
+package httpd
+
+import "strings"
+
+//TrailerPrefix是声明响应trailer的另一种方式：handler在写完报文主体后，
+//将"Trailer:"加上首部名作为key设置到Header中，这个首部会以去掉前缀后的名字作为trailer发出，
+//无需事先在Trailer首部中声明。
+//只有以chunk编码传输的响应才能携带trailer，未声明的trailer可能因为响应没有使用chunk编码而被丢弃
+const TrailerPrefix = "Trailer:"
+
+//declareTrailers记录handler在Trailer首部中声明的trailer，
+//它们的值在handler结束后才从Header中取出，不会随响应首部一起发送
+func (w *response) declareTrailers() {
+	w.trailers = w.trailers[:0]
+	for _, v := range w.header["Trailer"] {
+		for _, k := range strings.Split(v, ",") {
+			k = CanonicalHeaderKey(strings.TrimSpace(k))
+			if k == "" || forbiddenTrailer[k] {
+				continue
+			}
+			w.trailers = append(w.trailers, k)
+		}
+	}
+}
+
+//setupTrailerHeader在写出响应首部前，按最终的传输方式整理Trailer首部：
+//chunk编码时只保留合法的trailer名，否则trailer无法发出，删掉Trailer首部。
+//无法发出的trailer仍然记录在trailers中，它们的值同样不能随响应首部发出
+func (w *response) setupTrailerHeader() {
+	if !w.chunking || len(w.trailers) == 0 {
+		w.header.Del("Trailer")
+		return
+	}
+	w.header.Set("Trailer", strings.Join(w.trailers, ", "))
+}
+
+//excludeHeader返回写响应首部时需要跳过的key，即所有trailer
+func (w *response) excludeHeader() map[string]bool {
+	var exclude map[string]bool
+	for k := range w.header {
+		if strings.HasPrefix(k, TrailerPrefix) {
+			if exclude == nil {
+				exclude = make(map[string]bool)
+			}
+			exclude[k] = true
+		}
+	}
+	for _, k := range w.trailers {
+		if exclude == nil {
+			exclude = make(map[string]bool)
+		}
+		exclude[k] = true
+	}
+	return exclude
+}
+
+//writeTrailers写出chunk编码的结尾，即长度为0的块、trailer以及最后的空行
+func (w *response) writeTrailers() (err error) {
+	trailer := make(Header)
+	for _, k := range w.trailers {
+		if vv, ok := w.header[k]; ok {
+			trailer[k] = vv
+		}
+	}
+	for k, vv := range w.header {
+		if !strings.HasPrefix(k, TrailerPrefix) {
+			continue
+		}
+		k = CanonicalHeaderKey(k[len(TrailerPrefix):])
+		if !validHeaderKey(k) || forbiddenTrailer[k] {
+			continue
+		}
+		trailer[k] = append(trailer[k], vv...)
+	}
+	if _, err = w.w.WriteString("0\r\n"); err != nil {
+		return
+	}
+	if err = trailer.Write(w.w); err != nil {
+		return
+	}
+	_, err = w.w.WriteString("\r\n")
+	return
+}
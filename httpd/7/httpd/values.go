@@ -0,0 +1,64 @@
+package httpd
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+//Values用于保存query string以及表单，同一个key可以有多个value
+type Values map[string][]string
+
+//Get获取key对应的第一个value，如果不存在对应的key，则return ""
+func (v Values) Get(key string) string {
+	if vs := v[key]; len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+func (v Values) Set(key, value string) {
+	v[key] = []string{value}
+}
+
+func (v Values) Add(key, value string) {
+	v[key] = append(v[key], value)
+}
+
+func (v Values) Del(key string) {
+	delete(v, key)
+}
+
+func (v Values) Has(key string) bool {
+	_, ok := v[key]
+	return ok
+}
+
+//Encode编码为"a=1&b=2"的形式，key按字典序排列
+func (v Values) Encode() string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		ek := url.QueryEscape(k)
+		for _, value := range v[k] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(ek + "=" + url.QueryEscape(value))
+		}
+	}
+	return b.String()
+}
+
+//query string已经受到请求首部长度的限制，不再额外限制
+var queryOptions = FormOptions{MaxBytes: -1, MaxKeys: -1, MaxFieldBytes: -1}
+
+//ParseQuery解析"a=1&b=2"形式的字符串，'+'解码为空格，%XX按百分号编码解码。
+//无法解码的键值对会被跳过，并返回遇到的第一个错误
+func ParseQuery(query string) (Values, error) {
+	return ParseFormReader(strings.NewReader(query), queryOptions)
+}